@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The archive sits next to the tasks file and holds completed tasks,
+// oldest first.
+func (app *TodoApp) archiveFileName() string {
+	ext := filepath.Ext(app.fileName)
+	return strings.TrimSuffix(app.fileName, ext) + ".archive.json"
+}
+
+func (app *TodoApp) loadArchive() ([]Task, error) {
+	data, err := ioutil.ReadFile(app.archiveFileName())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading archive: %v", err)
+	}
+	if data, err = app.openData(app.archiveFileName(), data); err != nil {
+		return nil, fmt.Errorf("Error reading archive: %v", err)
+	}
+	var archived []Task
+	if err := json.Unmarshal(data, &archived); err != nil {
+		return nil, fmt.Errorf("Error parsing archive: %v", err)
+	}
+	return archived, nil
+}
+
+func (app *TodoApp) saveArchive(archived []Task) error {
+	data, err := json.MarshalIndent(archived, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Error encoding archive: %v", err)
+	}
+	if data, err = app.sealData(data); err != nil {
+		return fmt.Errorf("Error encrypting archive: %v", err)
+	}
+	if err := writeFileAtomic(app.archiveFileName(), data, 0644); err != nil {
+		return fmt.Errorf("Error writing archive: %v", err)
+	}
+	return nil
+}
+
+// archiveCompleted moves every completed or cancelled task out of the list and onto the
+// end of the archive.
+func (app *TodoApp) archiveCompleted() error {
+	var done, open []Task
+	for _, task := range app.tasks {
+		if !task.isOpen() {
+			done = append(done, task)
+		} else {
+			open = append(open, task)
+		}
+	}
+	if len(done) == 0 {
+		return errors.New("No completed or cancelled tasks to archive.")
+	}
+	archived, err := app.loadArchive()
+	if err != nil {
+		return err
+	}
+	if err := app.saveArchive(append(archived, done...)); err != nil {
+		return err
+	}
+	app.tasks = open
+	app.dirty = true
+	fmt.Fprintf(app.out, "Archived %d task(s) to %s.\n", len(done), app.archiveFileName())
+	app.relist()
+	return nil
+}
+
+func (app *TodoApp) listArchive() error {
+	archived, err := app.loadArchive()
+	if err != nil {
+		return err
+	}
+	if len(archived) == 0 {
+		fmt.Fprintln(app.out, "The archive is empty.")
+		return nil
+	}
+	for i, task := range archived {
+		details := ""
+		if !task.CompletedAt.IsZero() {
+			details = " (done " + app.formatDate(task.CompletedAt.Local().Format(dateLayout)) + ")"
+		}
+		fmt.Fprintf(app.out, "%d. %s%s\n", i+1, task.Description, details)
+	}
+	return nil
+}
+
+// trimArchive keeps only the given number of most recently archived tasks.
+func (app *TodoApp) trimArchive(arg string) error {
+	const usage = "archive-trim <count>"
+	if arg == "" {
+		return usageError("", "", usage)
+	}
+	keep, err := strconv.Atoi(arg)
+	if err != nil || keep < 0 {
+		return usageError("Not a count", arg, usage)
+	}
+	archived, err := app.loadArchive()
+	if err != nil {
+		return err
+	}
+	if len(archived) <= keep {
+		fmt.Fprintf(app.out, "Nothing to trim; the archive holds %d task(s).\n", len(archived))
+		return nil
+	}
+	trimmed := len(archived) - keep
+	if err := app.saveArchive(archived[trimmed:]); err != nil {
+		return err
+	}
+	fmt.Fprintf(app.out, "Trimmed %d archived task(s); kept %d.\n", trimmed, keep)
+	return nil
+}
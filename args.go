@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// usageError builds a consistent message for malformed arguments: what was
+// wrong, what the user typed, and the expected form of the command.
+func usageError(problem, arg, usage string) error {
+	if arg == "" {
+		return errors.New("Usage: " + usage)
+	}
+	return fmt.Errorf("%s: %q. Usage: %s", problem, arg, usage)
+}
+
+// taskNumberArg parses args as exactly one 1-based task number and returns
+// the matching index.
+func (app *TodoApp) taskNumberArg(args, usage string) (int, error) {
+	if args == "" {
+		return 0, usageError("", "", usage)
+	}
+	if len(strings.Fields(args)) > 1 {
+		return 0, usageError("Expected a single task number", args, usage)
+	}
+	taskNumber, err := strconv.Atoi(args)
+	if err != nil {
+		return 0, usageError("Not a task number", args, usage)
+	}
+	return app.taskIndex(taskNumber), nil
+}
+
+var errUnbalancedQuotes = errors.New(`Unbalanced quotes. Close every " or write \" for a literal quote.`)
+
+// splitArgs splits s on whitespace, keeping double-quoted sections together.
+// A backslash before a double quote makes it literal.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inQuotes, inArg := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && s[i+1] == '"':
+			current.WriteByte('"')
+			inArg = true
+			i++
+		case c == '"':
+			inQuotes = !inQuotes
+			inArg = true
+		case (c == ' ' || c == '\t') && !inQuotes:
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteByte(c)
+			inArg = true
+		}
+	}
+	if inQuotes {
+		return nil, errUnbalancedQuotes
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// textArg returns free text as typed, or the contents of the quotes when
+// the whole text is a single quoted string.
+func textArg(s string) (string, error) {
+	if !strings.Contains(s, `"`) {
+		return s, nil
+	}
+	args, err := splitArgs(s)
+	if err != nil {
+		return "", err
+	}
+	if len(args) == 1 && strings.HasPrefix(s, `"`) && strings.HasSuffix(s, `"`) {
+		return args[0], nil
+	}
+	return strings.ReplaceAll(s, `\"`, `"`), nil
+}
+
+// taskNumberAndText parses "<task number> <text>", where text is required.
+func (app *TodoApp) taskNumberAndText(args, usage string) (int, string, error) {
+	subParts := strings.SplitN(args, " ", 2)
+	if len(subParts) < 2 || strings.TrimSpace(subParts[1]) == "" {
+		if args == "" {
+			return 0, "", usageError("", "", usage)
+		}
+		return 0, "", usageError("Missing argument after task number", args, usage)
+	}
+	taskNumber, err := strconv.Atoi(subParts[0])
+	if err != nil {
+		return 0, "", usageError("Not a task number", subParts[0], usage)
+	}
+	text, err := textArg(strings.TrimSpace(subParts[1]))
+	if err != nil {
+		return 0, "", err
+	}
+	return app.taskIndex(taskNumber), text, nil
+}
+
+// taskNumbersArg parses one or more space-separated task numbers. A field
+// like "2-5" stands for every number in that range.
+func (app *TodoApp) taskNumbersArg(args, usage string) ([]int, error) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return nil, usageError("", "", usage)
+	}
+	indices := make([]int, 0, len(fields))
+	for _, field := range fields {
+		if from, to, ok := strings.Cut(field, "-"); ok && from != "" {
+			first, err1 := strconv.Atoi(from)
+			last, err2 := strconv.Atoi(to)
+			if err1 != nil || err2 != nil || first > last {
+				return nil, usageError("Not a range of task numbers", field, usage)
+			}
+			// Checked before expanding, so a huge range can't exhaust memory.
+			if first < 1 {
+				return nil, app.invalidTaskNumber(first - 1)
+			}
+			if last > len(app.tasks) {
+				return nil, app.invalidTaskNumber(last - 1)
+			}
+			for taskNumber := first; taskNumber <= last; taskNumber++ {
+				indices = append(indices, app.taskIndex(taskNumber))
+			}
+			continue
+		}
+		taskNumber, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, usageError("Not a task number", field, usage)
+		}
+		indices = append(indices, app.taskIndex(taskNumber))
+	}
+	return indices, nil
+}
+
+// taskNumberPair parses exactly two task numbers.
+func (app *TodoApp) taskNumberPair(args, usage string) (int, int, error) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return 0, 0, usageError("", "", usage)
+	}
+	if len(fields) != 2 {
+		return 0, 0, usageError("Expected two task numbers", args, usage)
+	}
+	first, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, 0, usageError("Not a task number", fields[0], usage)
+	}
+	second, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, 0, usageError("Not a task number", fields[1], usage)
+	}
+	return app.taskIndex(first), app.taskIndex(second), nil
+}
+
+// forceFlag removes a "-f" field from args and reports whether it was there.
+func forceFlag(args string) (string, bool) {
+	fields := strings.Fields(args)
+	for i, field := range fields {
+		if field == "-f" {
+			return strings.Join(append(fields[:i], fields[i+1:]...), " "), true
+		}
+	}
+	return args, false
+}
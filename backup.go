@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// defaultBackups is how many previous versions of the tasks file are kept
+// as <file>.1 (newest) to <file>.N. The backups config setting overrides
+// it, with a negative value turning backups off.
+const defaultBackups = 3
+
+func (app *TodoApp) backupCount() int {
+	if app.config.Backups < 0 {
+		return 0
+	}
+	if app.config.Backups > 0 {
+		return app.config.Backups
+	}
+	return defaultBackups
+}
+
+func backupFileName(fileName string, n int) string {
+	return fileName + "." + strconv.Itoa(n)
+}
+
+// rotateBackups copies the tasks file as it is on disk to <file>.1 before
+// it is overwritten, shifting older backups along. A file identical to the
+// newest backup is not backed up again, so repeated saves of the same
+// content don't push real history out. Once the list is encrypted, an
+// unencrypted file isn't backed up.
+func (app *TodoApp) rotateBackups() error {
+	count := app.backupCount()
+	if count == 0 {
+		return nil
+	}
+	current, err := ioutil.ReadFile(app.fileName)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if app.passphrase != "" && !isEncrypted(current) {
+		return nil
+	}
+	if newest, err := ioutil.ReadFile(backupFileName(app.fileName, 1)); err == nil && bytes.Equal(newest, current) {
+		return nil
+	}
+	for n := count; n > 1; n-- {
+		err := os.Rename(backupFileName(app.fileName, n-1), backupFileName(app.fileName, n))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return writeFileAtomic(backupFileName(app.fileName, 1), current, 0644)
+}
+
+func (app *TodoApp) removePlainBackups() error {
+	for n := 1; ; n++ {
+		data, err := ioutil.ReadFile(backupFileName(app.fileName, n))
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if !isEncrypted(data) {
+			if err := os.Remove(backupFileName(app.fileName, n)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (app *TodoApp) setBackups(arg string) error {
+	if strings.ToLower(arg) == "off" {
+		app.config.Backups = -1
+		app.saveConfig()
+		fmt.Fprintln(app.out, "Backups off.")
+		return nil
+	}
+	count, err := strconv.Atoi(arg)
+	if err != nil || count < 1 {
+		return usageError("Expected a positive number or \"off\"", arg, "backups <count|off>")
+	}
+	app.config.Backups = count
+	app.saveConfig()
+	fmt.Fprintf(app.out, "Keeping %d backups of %s.\n", count, app.fileName)
+	return nil
+}
+
+func (app *TodoApp) readBackup(n int) ([]Task, os.FileInfo, error) {
+	fileName := backupFileName(app.fileName, n)
+	info, err := os.Stat(fileName)
+	if os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("No backup %d: %s doesn't exist.", n, fileName)
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("Error reading backup: %v", err)
+	}
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error reading backup: %v", err)
+	}
+	if data, err = app.openData(fileName, data); err != nil {
+		return nil, nil, fmt.Errorf("Error reading backup: %v", err)
+	}
+	tasks, _, _, err := decodeTasks(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error parsing backup %d: %v", n, err)
+	}
+	assignIDs(tasks)
+	return tasks, info, nil
+}
+
+func (app *TodoApp) listBackups() {
+	found := false
+	for n := 1; ; n++ {
+		tasks, info, err := app.readBackup(n)
+		if info == nil {
+			break
+		}
+		found = true
+		if err != nil {
+			fmt.Fprintf(app.out, "  %d. %v\n", n, err)
+			continue
+		}
+		fmt.Fprintf(app.out, "  %d. %s, %d tasks\n", n, info.ModTime().Format("2006-01-02 15:04"), len(tasks))
+	}
+	if !found {
+		fmt.Fprintln(app.out, "No backups.")
+	}
+}
+
+// restoreBackup replaces the list with backup n. Saving afterwards backs up
+// the current file as usual, so a restore can itself be restored away.
+func (app *TodoApp) restoreBackup(arg string) error {
+	const usage = "restore-backup [n] [-f]"
+	arg, force := forceFlag(arg)
+	if arg == "" {
+		app.listBackups()
+		return nil
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 {
+		return usageError("Not a backup number", arg, usage)
+	}
+	tasks, info, err := app.readBackup(n)
+	if err != nil {
+		return err
+	}
+	when := info.ModTime().Format("2006-01-02 15:04")
+	if ok, err := app.confirm(fmt.Sprintf("Replace the current %d tasks with the %d from %s?", len(app.tasks), len(tasks), when), force); !ok {
+		return err
+	}
+	app.tasks = tasks
+	// A file that couldn't be loaded is backed up before being replaced.
+	app.notLoaded = ""
+	// Appending changes would skip the backup, so rewrite the file in full.
+	app.saved = nil
+	app.dirty = true
+	fmt.Fprintf(app.out, "Restored %d tasks from backup %d (%s).\n", len(tasks), n, when)
+	app.relist()
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// showCalendar prints the current month with the number of open tasks due
+// on each day. Narrow terminals get a compact grid with the counts listed
+// underneath.
+func (app *TodoApp) showCalendar() {
+	now := today()
+	first := now.AddDate(0, 0, 1-now.Day())
+	daysInMonth := first.AddDate(0, 1, -1).Day()
+
+	due := make(map[int]int)
+	for _, task := range app.tasks {
+		when, ok := storedDue(task.Due)
+		if !task.isOpen() || !ok {
+			continue
+		}
+		if when.Year() == now.Year() && when.Month() == now.Month() {
+			due[when.Day()]++
+		}
+	}
+
+	cellWidth := 7
+	if terminalWidth() < 7*cellWidth {
+		cellWidth = 4
+	}
+	title := first.Format("January 2006")
+	fmt.Fprintf(app.out, "%*s\n", (7*cellWidth+len(title))/2, title)
+	for _, name := range []string{"Su", "Mo", "Tu", "We", "Th", "Fr", "Sa"} {
+		fmt.Fprintf(app.out, "%*s", cellWidth, name)
+	}
+	fmt.Fprintln(app.out)
+
+	fmt.Fprint(app.out, strings.Repeat(" ", cellWidth*int(first.Weekday())))
+	for day := 1; day <= daysInMonth; day++ {
+		cell := fmt.Sprintf("%d", day)
+		if count := due[day]; count > 0 {
+			if cellWidth == 4 {
+				cell += "*"
+			} else {
+				cell += fmt.Sprintf("(%d)", count)
+			}
+		}
+		if day == now.Day() {
+			cell = app.markToday(cell)
+		}
+		fmt.Fprint(app.out, strings.Repeat(" ", max(cellWidth-visibleLen(cell), 1))+cell)
+		if (int(first.Weekday())+day)%7 == 0 {
+			fmt.Fprintln(app.out)
+		}
+	}
+	if (int(first.Weekday())+daysInMonth)%7 != 0 {
+		fmt.Fprintln(app.out)
+	}
+
+	if cellWidth == 4 && len(due) > 0 {
+		var counts []string
+		for day := 1; day <= daysInMonth; day++ {
+			if due[day] > 0 {
+				counts = append(counts, fmt.Sprintf("%d: %d", day, due[day]))
+			}
+		}
+		fmt.Fprintln(app.out, "Due:", strings.Join(counts, ", "))
+	}
+}
+
+// markToday highlights a calendar cell, with reverse video on a color
+// terminal and brackets otherwise.
+func (app *TodoApp) markToday(cell string) string {
+	if app.useColor() {
+		return app.colorize(colorReverse, cell)
+	}
+	return "[" + cell + "]"
+}
+
+// visibleLen is the printed width of s, ignoring color escape sequences.
+func visibleLen(s string) int {
+	n, inEscape := 0, false
+	for _, r := range s {
+		switch {
+		case r == '\x1b':
+			inEscape = true
+		case inEscape:
+			inEscape = r != 'm'
+		default:
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,707 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// command is one entry in the command table. processCommand dispatches on
+// it, and help, examples and shell completion are generated from it.
+type command struct {
+	name    string
+	aliases []string
+	args    string
+	summary string
+	example string
+	run     func(app *TodoApp, args string) error
+}
+
+// commands is filled in by init because the help handler reads it.
+var commands []command
+
+var commandsByName map[string]*command
+
+func init() {
+	commands = []command{
+		{name: "a", args: "<task description>", summary: "Add a new task; #words become tags and a trailing !, !! or !!! (or !low, !medium, !high) sets its priority", example: "a buy milk #errand !!",
+			run: func(app *TodoApp, args string) error {
+				if args == "" {
+					return usageError("", "", "a <task description>")
+				}
+				description, err := textArg(args)
+				if err != nil {
+					return err
+				}
+				if strings.HasPrefix(args, `"`) && strings.HasSuffix(args, `"`) {
+					return app.appendTask(app.newTask(description))
+				}
+				return app.addTask(description)
+			}},
+		{name: "ai", args: "<position> <task description>", summary: "Insert a task at a position, shifting the rest down", example: "ai 2 Call the bank",
+			run: func(app *TodoApp, args string) error {
+				index, description, err := app.taskNumberAndText(args, "ai <position> <task description>")
+				if err != nil {
+					return err
+				}
+				return app.insertAt(index, description)
+			}},
+		{name: "t", args: "", summary: "List all tasks", example: "t",
+			run: func(app *TodoApp, args string) error {
+				app.listTasks()
+				return nil
+			}},
+		{name: "cls", args: "", summary: "Clear the screen and list all tasks", example: "cls",
+			run: func(app *TodoApp, args string) error {
+				app.clearScreen()
+				return nil
+			}},
+		{name: "tpl", args: "save <task number> <name> | add <name> | list", summary: "Save a task's text, priority and tags as a template, add a task from one, or list them", example: "tpl save 3 weekly report",
+			run: (*TodoApp).templateCommand},
+		{name: "capture", args: "", summary: "Add tasks quickly, one per line, until a blank line", example: "capture",
+			run: func(app *TodoApp, args string) error {
+				return app.capture()
+			}},
+		{name: "x", args: "<task number> ... [-f]", summary: "Mark tasks as complete/incomplete (ranges like 2-5 work)", example: "x 2 4-6",
+			run: func(app *TodoApp, args string) error {
+				args, force := forceFlag(args)
+				indices, err := app.taskNumbersArg(args, "x <task number> ... [-f]")
+				if err != nil {
+					return err
+				}
+				return app.toggleTaskCompletion(indices, force)
+			}},
+		{name: "xm", args: "<task number> ... [-f]", summary: "Mark tasks complete/incomplete, moving completed ones to the end", example: "xm 2",
+			run: func(app *TodoApp, args string) error {
+				args, force := forceFlag(args)
+				indices, err := app.taskNumbersArg(args, "xm <task number> ... [-f]")
+				if err != nil {
+					return err
+				}
+				return app.toggleAndMove(indices, force, true)
+			}},
+		{name: "movedone", args: "", summary: "Toggle moving tasks to the end of the list when x completes them", example: "movedone",
+			run: func(app *TodoApp, args string) error {
+				app.config.MoveDone = !app.config.MoveDone
+				app.saveConfig()
+				if app.config.MoveDone {
+					fmt.Fprintln(app.out, "Completed tasks now move to the end of the list.")
+				} else {
+					fmt.Fprintln(app.out, "Completed tasks stay where they are.")
+				}
+				return nil
+			}},
+		{name: "xlast", args: "", summary: "Mark the last task in the list complete/incomplete", example: "xlast",
+			run: func(app *TodoApp, args string) error {
+				if len(app.tasks) == 0 {
+					return errors.New("No tasks.")
+				}
+				return app.toggleTaskCompletion([]int{len(app.tasks) - 1}, false)
+			}},
+		{name: "cancel", args: "<task number>", summary: "Mark a task abandoned [-], or reopen it", example: "cancel 3",
+			run: func(app *TodoApp, args string) error {
+				index, err := app.taskNumberArg(args, "cancel <task number>")
+				if err != nil {
+					return err
+				}
+				return app.cancelTask(index)
+			}},
+		{name: "doing", args: "<task number>", summary: "Mark a task in progress [~], or back to todo", example: "doing 3",
+			run: func(app *TodoApp, args string) error {
+				index, err := app.taskNumberArg(args, "doing <task number>")
+				if err != nil {
+					return err
+				}
+				return app.startTask(index)
+			}},
+		{name: "board", args: "", summary: "Show tasks grouped as Todo, Doing and Done", example: "board",
+			run: func(app *TodoApp, args string) error {
+				app.showBoard()
+				return nil
+			}},
+		{name: "done?", args: "<text> [-f]", summary: "Complete the open task that best matches the text, after asking", example: "done? milk",
+			run: func(app *TodoApp, args string) error {
+				args, force := forceFlag(args)
+				if args == "" {
+					return usageError("", "", "done? <text> [-f]")
+				}
+				return app.completeFuzzy(args, force)
+			}},
+		{name: "done", args: "<task number> <note>", summary: "Mark task complete and log a note about it", example: "done 2 paid by card",
+			run: func(app *TodoApp, args string) error {
+				index, note, err := app.taskNumberAndText(args, "done <task number> <note>")
+				if err != nil {
+					return err
+				}
+				return app.completeWithNote(index, note)
+			}},
+		{name: "d", args: "<task number> ... [-f]", summary: "Remove tasks", example: "d 5",
+			run: func(app *TodoApp, args string) error {
+				args, force := forceFlag(args)
+				indices, err := app.taskNumbersArg(args, "d <task number> ... [-f]")
+				if err != nil {
+					return err
+				}
+				return app.removeTasks(indices, force)
+			}},
+		{name: "dlast", args: "", summary: "Remove the last task in the list", example: "dlast",
+			run: func(app *TodoApp, args string) error {
+				if len(app.tasks) == 0 {
+					return errors.New("No tasks.")
+				}
+				return app.removeTasks([]int{len(app.tasks) - 1}, false)
+			}},
+		{name: "h", args: "<task number>", summary: "Move task higher", example: "h 3",
+			run: func(app *TodoApp, args string) error {
+				index, err := app.taskNumberArg(args, "h <task number>")
+				if err != nil {
+					return err
+				}
+				return app.moveTaskUp(index)
+			}},
+		{name: "l", args: "<task number>", summary: "Move task lower", example: "l 1",
+			run: func(app *TodoApp, args string) error {
+				index, err := app.taskNumberArg(args, "l <task number>")
+				if err != nil {
+					return err
+				}
+				return app.moveTaskDown(index)
+			}},
+		{name: "order", args: "<task number> ...", summary: "Reorder every task at once, e.g. order 3 1 2", example: "order 3 1 2",
+			run: func(app *TodoApp, args string) error {
+				indices, err := app.taskNumbersArg(args, "order <task number> ...")
+				if err != nil {
+					return err
+				}
+				return app.reorderTasks(indices)
+			}},
+		{name: "r", args: "<task number> <new description>", summary: "Rename task", example: "r 2 buy oat milk",
+			run: func(app *TodoApp, args string) error {
+				index, description, err := app.taskNumberAndText(args, "r <task number> <new task description>")
+				if err != nil {
+					return err
+				}
+				return app.renameTask(index, description)
+			}},
+		{name: "append", args: "<task number> <text>", summary: "Add text to the end of a task's description", example: "append 2 and bread",
+			run: func(app *TodoApp, args string) error {
+				index, text, err := app.taskNumberAndText(args, "append <task number> <text>")
+				if err != nil {
+					return err
+				}
+				return app.appendToTask(index, text)
+			}},
+		{name: "split", args: "<task number>", summary: "Replace a task with several new ones, entered one per line", example: "split 4",
+			run: func(app *TodoApp, args string) error {
+				index, err := app.taskNumberArg(args, "split <task number>")
+				if err != nil {
+					return err
+				}
+				return app.splitTask(index)
+			}},
+		{name: "combine", args: "<task number> <task number> ...", summary: "Merge tasks into the first one", example: "combine 2 5",
+			run: func(app *TodoApp, args string) error {
+				indices, err := app.taskNumbersArg(args, "combine <task number> <task number> ...")
+				if err != nil {
+					return err
+				}
+				return app.combineTasks(indices)
+			}},
+		{name: "rn", args: "<old description> <new description>", summary: "Rename the task with that exact description", example: "rn \"buy milk\" \"buy oat milk\"",
+			run: func(app *TodoApp, args string) error {
+				if len(strings.Fields(args)) < 2 {
+					return usageError("Expected an old and a new description", args, "rn <old description> <new description>")
+				}
+				return app.renameByDescription(args)
+			}},
+		{name: "plain", args: "[-strike]", summary: "Print a numbered list without status, optionally striking completed tasks", example: "plain -strike",
+			run: func(app *TodoApp, args string) error {
+				switch args {
+				case "":
+					app.printPlain(false)
+				case "-strike":
+					app.printPlain(true)
+				default:
+					return usageError("Unknown option", args, "plain [-strike]")
+				}
+				return nil
+			}},
+		{name: "tag", args: "<task number> <tag> ...", summary: "Add tags to a task", example: "tag 2 errand home",
+			run: func(app *TodoApp, args string) error {
+				index, tags, err := app.taskNumberAndText(args, "tag <task number> <tag> ...")
+				if err != nil {
+					return err
+				}
+				return app.tagTask(index, strings.Fields(tags))
+			}},
+		{name: "link", args: "<task number> <url|none>", summary: "Attach a URL to a task", example: "link 2 https://example.com/issue/42",
+			run: func(app *TodoApp, args string) error {
+				index, link, err := app.taskNumberAndText(args, "link <task number> <url|none>")
+				if err != nil {
+					return err
+				}
+				return app.setLink(index, link)
+			}},
+		{name: "assign", args: "<task number> <name|none>", summary: "Assign a task to someone", example: "assign 3 sam",
+			run: func(app *TodoApp, args string) error {
+				index, name, err := app.taskNumberAndText(args, "assign <task number> <name|none>")
+				if err != nil {
+					return err
+				}
+				return app.assignTask(index, name)
+			}},
+		{name: "unassigned", args: "[-all]", summary: "List open tasks nobody is assigned to, or all such tasks with -all", example: "unassigned",
+			run: (*TodoApp).listUnassigned},
+		{name: "digest", args: "", summary: "Print open tasks by assignee or tag and this week's completions as plain text", example: "digest",
+			run: (*TodoApp).showDigest},
+		{name: "block", args: "<task number> <blocking task number>", summary: "Make a task wait until another is finished", example: "block 4 2",
+			run: func(app *TodoApp, args string) error {
+				index, blocker, err := app.taskNumberPair(args, "block <task number> <blocking task number>")
+				if err != nil {
+					return err
+				}
+				return app.blockTask(index, blocker)
+			}},
+		{name: "unblock", args: "<task number> <blocking task number>", summary: "Remove a dependency added with block", example: "unblock 4 2",
+			run: func(app *TodoApp, args string) error {
+				index, blocker, err := app.taskNumberPair(args, "unblock <task number> <blocking task number>")
+				if err != nil {
+					return err
+				}
+				return app.unblockTask(index, blocker)
+			}},
+		{name: "next", args: "", summary: "Show the most important task that nothing blocks", example: "next",
+			run: func(app *TodoApp, args string) error {
+				app.showNext()
+				return nil
+			}},
+		{name: "deps", args: "<task number>", summary: "Show the tasks a task waits on, as a tree", example: "deps 4",
+			run: func(app *TodoApp, args string) error {
+				index, err := app.taskNumberArg(args, "deps <task number>")
+				if err != nil {
+					return err
+				}
+				return app.showDependencies(index)
+			}},
+		{name: "sub", args: "<task number> <description>", summary: "Add a subtask beneath a task", example: "sub 2 Buy stamps",
+			run: func(app *TodoApp, args string) error {
+				index, description, err := app.taskNumberAndText(args, "sub <task number> <description>")
+				if err != nil {
+					return err
+				}
+				return app.addSubtask(index, description)
+			}},
+		{name: "untag", args: "<task number> <tag> ...", summary: "Remove tags from a task", example: "untag 2 home",
+			run: func(app *TodoApp, args string) error {
+				index, tags, err := app.taskNumberAndText(args, "untag <task number> <tag> ...")
+				if err != nil {
+					return err
+				}
+				return app.untagTask(index, strings.Fields(tags))
+			}},
+		{name: "xtag", args: "<tag> [-f]", summary: "Mark every open task with a tag complete", example: "xtag errand",
+			run: func(app *TodoApp, args string) error {
+				args, force := forceFlag(args)
+				if args == "" || len(strings.Fields(args)) > 1 {
+					return usageError("Expected one tag", args, "xtag <tag> [-f]")
+				}
+				return app.completeTagged(args, force)
+			}},
+		{name: "clear", args: "<task number> [-f]", summary: "Remove all of a task's metadata, keeping its description and completion", example: "clear 4",
+			run: func(app *TodoApp, args string) error {
+				args, force := forceFlag(args)
+				index, err := app.taskNumberArg(args, "clear <task number> [-f]")
+				if err != nil {
+					return err
+				}
+				return app.clearMetadata(index, force)
+			}},
+		{name: "pri+", args: "<task number>", summary: "Raise a task's priority one level", example: "pri+ 2",
+			run: func(app *TodoApp, args string) error {
+				index, err := app.taskNumberArg(args, "pri+ <task number>")
+				if err != nil {
+					return err
+				}
+				return app.bumpPriority(index, 1)
+			}},
+		{name: "pri-", args: "<task number>", summary: "Lower a task's priority one level", example: "pri- 2",
+			run: func(app *TodoApp, args string) error {
+				index, err := app.taskNumberArg(args, "pri- <task number>")
+				if err != nil {
+					return err
+				}
+				return app.bumpPriority(index, -1)
+			}},
+		{name: "open", args: "[-json]", summary: "List only the tasks still to do", example: "open -json",
+			run: func(app *TodoApp, args string) error {
+				switch args {
+				case "":
+					return app.listOpen(false)
+				case "-json":
+					return app.listOpen(true)
+				}
+				return usageError("Unknown option", args, "open [-json]")
+			}},
+		{name: "show", args: "<task number> [-json]", summary: "Show every field of a task", example: "show 2 -json",
+			run: func(app *TodoApp, args string) error {
+				fields := strings.Fields(args)
+				asJSON := len(fields) == 2 && fields[1] == "-json"
+				if asJSON {
+					args = fields[0]
+				}
+				index, err := app.taskNumberArg(args, "show <task number> [-json]")
+				if err != nil {
+					return err
+				}
+				return app.showTask(index, asJSON)
+			}},
+		{name: "p", args: "<task number> <none|low|medium|high>", summary: "Set a task's priority", example: "p 2 high",
+			run: func(app *TodoApp, args string) error {
+				index, priority, err := app.taskNumberAndText(args, "p <task number> <none|low|medium|high>")
+				if err != nil {
+					return err
+				}
+				return app.setPriority(index, priority)
+			}},
+		{name: "sort", args: "<p|done>", summary: "Sort by priority (then due date, then creation order), or order completed tasks by when they were finished", example: "sort p",
+			run: func(app *TodoApp, args string) error {
+				return app.sortTasks(strings.ToLower(args))
+			}},
+		{name: "estimate", args: "<task number> <duration|none>", summary: "Record how long a task should take", example: "estimate 3 1h30m",
+			run: func(app *TodoApp, args string) error {
+				index, value, err := app.taskNumberAndText(args, "estimate <task number> <duration|none>")
+				if err != nil {
+					return err
+				}
+				return app.setEstimate(index, value)
+			}},
+		{name: "progress", args: "[-weighted]", summary: "Show how much of the list is done, optionally weighted by estimates", example: "progress -weighted",
+			run: (*TodoApp).showProgress},
+		{name: "due", args: "<task number> <date [HH:MM]|none>", summary: "Set or clear a due date", example: "due 2 tomorrow 17:00",
+			run: func(app *TodoApp, args string) error {
+				index, due, err := app.taskNumberAndText(args, "due <task number> <date [HH:MM]|none>")
+				if err != nil {
+					return err
+				}
+				return app.setDue(index, due)
+			}},
+		{name: "shift", args: "<days> [-f]", summary: "Move every open task's due date by days (negative for earlier)", example: "shift 7",
+			run: (*TodoApp).shiftDueDates},
+		{name: "start-on", args: "<task number> <date|none>", summary: "Hide task until a date (YYYY-MM-DD)", example: "start-on 3 2026-11-01",
+			run: func(app *TodoApp, args string) error {
+				index, date, err := app.taskNumberAndText(args, "start-on <task number> <date|none>")
+				if err != nil {
+					return err
+				}
+				return app.setStartDate(index, date)
+			}},
+		{name: "deferred", args: "", summary: "List tasks that haven't started yet", example: "deferred",
+			run: func(app *TodoApp, args string) error {
+				app.listDeferred()
+				return nil
+			}},
+		{name: "someday", args: "[task number]", summary: "Move a task to the someday list, or show that list", example: "someday 4",
+			run: func(app *TodoApp, args string) error {
+				if args == "" {
+					app.listSomeday()
+					return nil
+				}
+				index, err := app.taskNumberArg(args, "someday [task number]")
+				if err != nil {
+					return err
+				}
+				return app.setSomeday(index, true)
+			}},
+		{name: "activate", args: "<task number>", summary: "Bring a task back from the someday list", example: "activate 4",
+			run: func(app *TodoApp, args string) error {
+				index, err := app.taskNumberArg(args, "activate <task number>")
+				if err != nil {
+					return err
+				}
+				return app.setSomeday(index, false)
+			}},
+		{name: "wc", args: "[-notes]", summary: "Count tasks, words and characters, optionally including notes", example: "wc -notes",
+			run: (*TodoApp).countWords},
+		{name: "fx", args: "<regex>", summary: "List tasks whose description matches a regular expression", example: `fx (?i)^call\b`,
+			run: (*TodoApp).findRegexp},
+		{name: "f", args: "<#tag|@name> ... [-or]", summary: "List tasks with all the given tags and assignees, or any of them with -or", example: "f #work @sam",
+			run: (*TodoApp).filterTasks},
+		{name: "new", args: "", summary: "List tasks added today", example: "new",
+			run: func(app *TodoApp, args string) error {
+				app.listAddedToday()
+				return nil
+			}},
+		{name: "upcoming", args: "[days]", summary: "List open tasks due today or within the next days (default 3)", example: "upcoming 7",
+			run: (*TodoApp).listUpcoming},
+		{name: "overdue", args: "", summary: "List open tasks past their due date, most overdue first", example: "overdue",
+			run: func(app *TodoApp, args string) error {
+				app.listOverdue()
+				return nil
+			}},
+		{name: "stale", args: "[days]", summary: "List open tasks created more than days ago (default 7), oldest first", example: "stale 14",
+			run: (*TodoApp).listStale},
+		{name: "plan", args: "[task number]", summary: "Show today's plan, or add/remove a task (clears overnight)", example: "plan 2",
+			run: func(app *TodoApp, args string) error {
+				if args == "" {
+					app.showPlan()
+					return nil
+				}
+				index, err := app.taskNumberArg(args, "plan [task number]")
+				if err != nil {
+					return err
+				}
+				return app.togglePlan(index)
+			}},
+		{name: "export", args: "[-all] <file>", summary: "Write the tasks the listing shows to a JSON file, or all with -all", example: "export week.json",
+			run: (*TodoApp).exportTasks},
+		{name: "import", args: "md <filename>", summary: "Import a Markdown checklist (- [ ] / - [x])", example: "import md notes.md",
+			run: func(app *TodoApp, args string) error {
+				subParts := strings.SplitN(args, " ", 2)
+				if len(subParts) != 2 || strings.ToLower(subParts[0]) != "md" {
+					return usageError("Unsupported import", args, "import md <filename>")
+				}
+				return app.importMarkdown(strings.TrimSpace(subParts[1]))
+			}},
+		{name: "u", args: "", summary: "Undo the last change (works across sessions)", example: "u",
+			run: func(app *TodoApp, args string) error {
+				return app.undo()
+			}},
+		{name: "history", args: "[clear]", summary: "List what undo would revert, most recent first, or forget it", example: "history",
+			run: func(app *TodoApp, args string) error {
+				return app.showHistory(strings.ToLower(args))
+			}},
+		{name: "raw", args: "", summary: "Print the tasks file exactly as stored on disk", example: "raw",
+			run: func(app *TodoApp, args string) error {
+				return app.showRaw()
+			}},
+		{name: "diff", args: "", summary: "Show changes not yet saved to disk", example: "diff",
+			run: func(app *TodoApp, args string) error {
+				return app.showDiff()
+			}},
+		{name: "streak", args: "[on|off]", summary: "Show completion streaks, or turn tracking on/off", example: "streak on",
+			run: func(app *TodoApp, args string) error {
+				return app.showStreak(strings.ToLower(args))
+			}},
+		{name: "quiet", args: "", summary: "Toggle listing tasks after each change", example: "quiet",
+			run: func(app *TodoApp, args string) error {
+				app.quiet = !app.quiet
+				if app.quiet {
+					fmt.Fprintln(app.out, "Quiet mode on.")
+				} else {
+					fmt.Fprintln(app.out, "Quiet mode off.")
+				}
+				return nil
+			}},
+		{name: "prompt", args: "", summary: "Toggle showing the open-task count in the prompt", example: "prompt",
+			run: func(app *TodoApp, args string) error {
+				app.config.PromptCount = !app.config.PromptCount
+				app.saveConfig()
+				if app.config.PromptCount {
+					fmt.Fprintln(app.out, "Open-task count in prompt on.")
+				} else {
+					fmt.Fprintln(app.out, "Open-task count in prompt off.")
+				}
+				return nil
+			}},
+		{name: "autosort", args: "", summary: "Toggle keeping completed tasks below open ones", example: "autosort",
+			run: func(app *TodoApp, args string) error {
+				app.config.CompletedLast = !app.config.CompletedLast
+				app.saveConfig()
+				if app.config.CompletedLast {
+					fmt.Fprintln(app.out, "Completed tasks now sort to the bottom.")
+					app.sortCompletedLast()
+					app.relist()
+				} else {
+					fmt.Fprintln(app.out, "Completed tasks keep their position.")
+				}
+				return nil
+			}},
+		{name: "autoparent", args: "", summary: "Toggle completing a task once all its subtasks are done", example: "autoparent",
+			run: func(app *TodoApp, args string) error {
+				app.config.AutoCompleteParent = !app.config.AutoCompleteParent
+				app.saveConfig()
+				if app.config.AutoCompleteParent {
+					fmt.Fprintln(app.out, "Tasks now complete when their last subtask does.")
+				} else {
+					fmt.Fprintln(app.out, "Tasks stay open until completed themselves.")
+				}
+				return nil
+			}},
+		{name: "notify", args: "", summary: "Toggle desktop notifications when tasks fall due", example: "notify",
+			run: func(app *TodoApp, args string) error {
+				app.config.Notify = !app.config.Notify
+				app.saveConfig()
+				if app.config.Notify {
+					fmt.Fprintln(app.out, "Due-task notifications on (interactive sessions only).")
+				} else {
+					fmt.Fprintln(app.out, "Due-task notifications off.")
+				}
+				return nil
+			}},
+		{name: "recent", args: "<count|off>", summary: "Show the most recently completed tasks above the list", example: "recent 3",
+			run: (*TodoApp).setRecentDone},
+		{name: "backups", args: "<count|off>", summary: "Choose how many previous versions of the tasks file to keep (default 3)", example: "backups 5",
+			run: (*TodoApp).setBackups},
+		{name: "restore-backup", args: "[n] [-f]", summary: "Replace the list with backup n, or list the backups", example: "restore-backup 2",
+			run: (*TodoApp).restoreBackup},
+		{name: "renumber", args: "", summary: "Toggle numbering listed tasks 1..N instead of by position", example: "renumber",
+			run: func(app *TodoApp, args string) error {
+				app.toggleRelativeNumbers()
+				return nil
+			}},
+		{name: "numbers", args: "", summary: "Toggle showing task numbers in listings", example: "numbers",
+			run: func(app *TodoApp, args string) error {
+				app.config.HideNumbers = !app.config.HideNumbers
+				app.saveConfig()
+				if app.config.HideNumbers {
+					fmt.Fprintln(app.out, "Task numbers hidden in listings. Commands still take them.")
+				} else {
+					fmt.Fprintln(app.out, "Task numbers shown in listings.")
+				}
+				return nil
+			}},
+		{name: "limit", args: "<count|off> [strict]", summary: "Warn (or with strict, refuse to add) past a number of open tasks", example: "limit 10 strict",
+			run: (*TodoApp).setOpenLimit},
+		{name: "calendar", args: "", summary: "Show this month with the number of open tasks due each day", example: "calendar",
+			run: func(app *TodoApp, args string) error {
+				app.showCalendar()
+				return nil
+			}},
+		{name: "heatmap", args: "[weeks]", summary: "Show completions per day over recent weeks (default 8)", example: "heatmap 12",
+			run: (*TodoApp).showHeatmap},
+		{name: "recur", args: "<task number> <daily|weekly|monthly|mon,wed,...|none>", summary: "Repeat a task with a due date; completing it moves the due date on", example: "recur 2 mon,wed,fri",
+			run: func(app *TodoApp, args string) error {
+				index, value, err := app.taskNumberAndText(args, "recur <task number> <daily|weekly|monthly|mon,wed,...|none>")
+				if err != nil {
+					return err
+				}
+				return app.setRecurrence(index, value)
+			}},
+		{name: "catchup", args: "<skip|accumulate>", summary: "On startup, skip missed repeats or add each as its own task", example: "catchup skip",
+			run: (*TodoApp).setCatchUp},
+		{name: "compact", args: "[-done] [-f]", summary: "Trim descriptions and remove duplicate tasks (and with -done, completed ones) after a preview, rewriting the tasks file in full", example: "compact -done",
+			run: (*TodoApp).compactTasks},
+		{name: "archive", args: "", summary: "Move completed and cancelled tasks to the archive file", example: "archive",
+			run: func(app *TodoApp, args string) error {
+				return app.archiveCompleted()
+			}},
+		{name: "archive-list", args: "", summary: "List archived tasks, oldest first", example: "archive-list",
+			run: func(app *TodoApp, args string) error {
+				return app.listArchive()
+			}},
+		{name: "archive-trim", args: "<count>", summary: "Keep only the most recently archived tasks", example: "archive-trim 100",
+			run: (*TodoApp).trimArchive},
+		{name: "encrypt", args: "", summary: "Encrypt the tasks file with a passphrase (or set " + passphraseEnv + ")", example: "encrypt",
+			run: func(app *TodoApp, args string) error {
+				return app.setEncryption(true)
+			}},
+		{name: "decrypt", args: "", summary: "Save the tasks file unencrypted again", example: "decrypt",
+			run: func(app *TodoApp, args string) error {
+				return app.setEncryption(false)
+			}},
+		{name: "watch", args: "", summary: "Reload and list the tasks whenever the file changes on disk; Enter stops", example: "watch",
+			run: func(app *TodoApp, args string) error {
+				return app.watchFile()
+			}},
+		{name: "file", args: "<path>", summary: "Save the current list and switch to another tasks file", example: "file work.json",
+			run: func(app *TodoApp, args string) error {
+				fileName, err := textArg(args)
+				if err != nil {
+					return err
+				}
+				return app.switchFile(fileName)
+			}},
+		{name: "config", args: "[get <key> | set <key> <value>]", summary: "Show every setting, or read or change one by key", example: "config set openLimit 20",
+			run: (*TodoApp).configCommand},
+		{name: "jsonfmt", args: "<compact|lines|pretty [width]>", summary: "Choose how the tasks file is written (default pretty, width 2)", example: "jsonfmt lines",
+			run: (*TodoApp).setJSONFormat},
+		{name: "datefmt", args: "<pattern|iso>", summary: "Set how dates are shown, e.g. DD.MM.YYYY (input stays YYYY-MM-DD)", example: "datefmt DD.MM.YYYY",
+			run: (*TodoApp).setDateFormat},
+		{name: "completion", args: "<bash|zsh|fish>", summary: "Print a shell completion script", example: "completion bash",
+			run: func(app *TodoApp, args string) error {
+				return app.printCompletion(strings.ToLower(args))
+			}},
+		{name: "examples", args: "", summary: "Show an example of every command", example: "examples",
+			run: func(app *TodoApp, args string) error {
+				printExamples(app.out)
+				return nil
+			}},
+		{name: "?", aliases: []string{"help"}, args: "", summary: "Show this help message", example: "?",
+			run: func(app *TodoApp, args string) error {
+				printHelp(app.out)
+				return nil
+			}},
+		{name: "q", aliases: []string{"quit", "exit"}, args: "", summary: "Quit the application", example: "q",
+			run: func(app *TodoApp, args string) error {
+				if err := app.flush(); err != nil {
+					fmt.Fprintln(app.out, err)
+				}
+				os.Exit(0)
+				return nil
+			}},
+	}
+
+	commandsByName = make(map[string]*command)
+	for i := range commands {
+		c := &commands[i]
+		commandsByName[c.name] = c
+		for _, alias := range c.aliases {
+			commandsByName[alias] = c
+		}
+	}
+}
+
+func commandNames() []string {
+	names := make([]string, len(commands))
+	for i, c := range commands {
+		names[i] = c.name
+	}
+	return names
+}
+
+// processCommand runs one command line. Failures are returned rather than
+// printed so one-shot mode can turn them into an exit status.
+func (app *TodoApp) processCommand(line string) error {
+	parts := strings.SplitN(line, " ", 2)
+	action := strings.ToLower(parts[0])
+	args := ""
+	if len(parts) > 1 {
+		args = strings.TrimSpace(parts[1])
+	}
+
+	if c, ok := commandsByName[action]; ok {
+		return c.run(app, args)
+	}
+	if ok, err := app.runPlugin(action, args); ok {
+		return err
+	}
+	if suggestion, ok := closestCommand(action); ok {
+		return fmt.Errorf("Unknown command. Did you mean '%s'? Type \"?\" for help.", suggestion)
+	}
+	return errors.New("Unknown command. Type \"?\" for help.")
+}
+
+func printHelp(w io.Writer) {
+	fmt.Fprintln(w, "Available commands:")
+	for _, c := range commands {
+		usage := c.name
+		if c.args != "" {
+			usage += " " + c.args
+		}
+		summary := c.summary
+		if len(c.aliases) > 0 {
+			summary += " (also: " + strings.Join(c.aliases, ", ") + ")"
+		}
+		fmt.Fprintf(w, "  %s - %s\n", usage, summary)
+	}
+	fmt.Fprintln(w, "Any other command runs todo-<command> from your PATH, if there is one.")
+}
+
+func printExamples(w io.Writer) {
+	fmt.Fprintln(w, "Examples (type \"?\" for what each command does):")
+	for _, c := range commands {
+		fmt.Fprintln(w, "  "+c.example)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func completionScript(shell, program string) (string, bool) {
+	var names []string
+	for _, name := range commandNames() {
+		// A bare "?" would be expanded as a glob by the shell.
+		if name != "?" {
+			names = append(names, name)
+		}
+	}
+	words := strings.Join(names, " ")
+	switch shell {
+	case "bash":
+		// Read compgen's output with mapfile: assigning an unquoted $(...)
+		// to COMPREPLY would expand names like "done?" against files in the
+		// current directory.
+		return fmt.Sprintf(`_%[1]s_complete() {
+    if [ "$COMP_CWORD" -eq 1 ]; then
+        mapfile -t COMPREPLY < <(compgen -W "%[2]s" -- "${COMP_WORDS[COMP_CWORD]}")
+    fi
+}
+complete -F _%[1]s_complete %[1]s
+`, program, words), true
+	case "zsh":
+		return fmt.Sprintf(`#compdef %[1]s
+_arguments '1:command:(%[2]s)'
+`, program, words), true
+	case "fish":
+		return fmt.Sprintf("complete -c %s -f -n '__fish_use_subcommand' -a '%s'\n", program, words), true
+	}
+	return "", false
+}
+
+func (app *TodoApp) printCompletion(shell string) error {
+	script, ok := completionScript(shell, programName())
+	if !ok {
+		return usageError("Unsupported shell", shell, "completion <bash|zsh|fish>")
+	}
+	fmt.Fprint(app.out, script)
+	return nil
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type Config struct {
+	Streaks            bool   `json:"streaks"`
+	PromptCount        bool   `json:"promptCount"`
+	UndoDepth          int    `json:"undoDepth,omitempty"`
+	CompletedLast      bool   `json:"completedLast"`
+	Notify             bool   `json:"notify"`
+	DateFormat         string `json:"dateFormat,omitempty"`
+	RecentDone         int    `json:"recentDone,omitempty"`
+	OpenLimit          int    `json:"openLimit,omitempty"`
+	StrictLimit        bool   `json:"strictLimit,omitempty"`
+	HideNumbers        bool   `json:"hideNumbers,omitempty"`
+	RecurCatchUp       string `json:"recurCatchUp,omitempty"`
+	CompactJSON        bool   `json:"compactJSON,omitempty"`
+	JSONIndent         int    `json:"jsonIndent,omitempty"`
+	MaxFileMB          int    `json:"maxFileMB,omitempty"`
+	AutoCompleteParent bool   `json:"autoCompleteParent,omitempty"`
+	Backups            int    `json:"backups,omitempty"`
+	File               string `json:"file,omitempty"`
+	Color              string `json:"color,omitempty"`
+	View               string `json:"view,omitempty"`
+	JSONLines          bool   `json:"jsonLines,omitempty"`
+	RelativeNumbers    bool   `json:"relativeNumbers,omitempty"`
+	MoveDone           bool   `json:"moveDone,omitempty"`
+}
+
+func configFileName() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ".todo-config.json"
+	}
+	return filepath.Join(dir, "todo-cli", "config.json")
+}
+
+func (app *TodoApp) loadConfig() {
+	data, err := ioutil.ReadFile(configFileName())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Fprintln(app.out, "Error reading config:", err)
+		}
+		return
+	}
+	if err := json.Unmarshal(data, &app.config); err != nil {
+		fmt.Fprintln(app.out, "Error parsing config:", err)
+	}
+}
+
+// setJSONFormat switches the tasks file between compact and indented JSON
+// and rewrites it in the new layout.
+func (app *TodoApp) setJSONFormat(args string) error {
+	const usage = "jsonfmt <compact|lines|pretty [width]>"
+	fields := strings.Fields(strings.ToLower(args))
+	switch {
+	case len(fields) == 1 && fields[0] == "compact":
+		app.config.CompactJSON = true
+		app.config.JSONLines = false
+		fmt.Fprintln(app.out, "Tasks will be saved as compact JSON.")
+	case len(fields) == 1 && fields[0] == "lines":
+		app.config.JSONLines = true
+		fmt.Fprintln(app.out, "Tasks will be saved one per line, and changes appended without rewriting the file.")
+	case len(fields) >= 1 && len(fields) <= 2 && fields[0] == "pretty":
+		indent := 2
+		if len(fields) == 2 {
+			n, err := strconv.Atoi(fields[1])
+			if err != nil || n < 1 || n > 8 {
+				return usageError("Width must be 1-8", fields[1], usage)
+			}
+			indent = n
+		}
+		app.config.CompactJSON = false
+		app.config.JSONLines = false
+		app.config.JSONIndent = indent
+		fmt.Fprintf(app.out, "Tasks will be saved as JSON indented by %d.\n", indent)
+	default:
+		return usageError("Unknown format", args, usage)
+	}
+	app.saveConfig()
+	app.dirty = true
+	return nil
+}
+
+func (app *TodoApp) saveConfig() {
+	config := app.config
+	for _, key := range configKeys() {
+		if !app.envKeys[key] {
+			continue
+		}
+		field, _ := configField(&config, key)
+		var env Config
+		envField, _ := configField(&env, key)
+		setConfigValue(envField, os.Getenv(configEnv(key)))
+		if reflect.DeepEqual(field.Interface(), envField.Interface()) {
+			saved, _ := configField(&app.fileConfig, key)
+			field.Set(saved)
+			continue
+		}
+		// Changed during this session, so the new value is what to save.
+		delete(app.envKeys, key)
+		fmt.Fprintf(app.out, "Saved %s, but %s overrides it while set.\n", key, configEnv(key))
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		fmt.Fprintln(app.out, "Error encoding config:", err)
+		return
+	}
+	fileName := configFileName()
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		fmt.Fprintln(app.out, "Error writing config:", err)
+		return
+	}
+	if err := writeFileAtomic(fileName, data, 0644); err != nil {
+		fmt.Fprintln(app.out, "Error writing config:", err)
+		return
+	}
+	app.fileConfig = config
+}
+
+// configDocs describes each key for the config command.
+var configDocs = map[string]string{
+	"streaks":            "record completions for streak and heatmap (true/false)",
+	"promptCount":        "show the open-task count in the prompt (true/false)",
+	"undoDepth":          "how many changes undo remembers (0 for the default)",
+	"completedLast":      "keep completed tasks below open ones (true/false)",
+	"notify":             "desktop notification when a task falls due (true/false)",
+	"dateFormat":         "date display pattern such as DD/MM/YYYY (empty for ISO)",
+	"recentDone":         "recently completed tasks shown above the list (0 for none)",
+	"openLimit":          "warn above this many open tasks (0 for no limit)",
+	"strictLimit":        "refuse to add tasks over openLimit (true/false)",
+	"hideNumbers":        "leave task numbers out of listings (true/false)",
+	"recurCatchUp":       "missed recurring occurrences: skip or accumulate",
+	"compactJSON":        "write the tasks file without indentation (true/false)",
+	"jsonIndent":         "indent width for the tasks file (0 for 2)",
+	"maxFileMB":          "largest tasks file to load, in MB (0 for 10)",
+	"autoCompleteParent": "complete a task when its last subtask is done (true/false)",
+	"backups":            "backups of the tasks file to keep (0 for 3, -1 for none)",
+	"file":               "tasks file used when -file isn't given",
+	"color":              "\"never\" to turn color off, empty for automatic",
+	"view":               "shown at startup: empty for the list, board or open",
+	"jsonLines":          "write one task per line and append new tasks (true/false)",
+	"relativeNumbers":    "number listed tasks 1..N with hidden ones after (true/false)",
+	"moveDone":           "move tasks to the end of the list when x completes them (true/false)",
+}
+
+// configKeys returns the JSON keys of Config in declaration order.
+func configKeys() []string {
+	t := reflect.TypeOf(Config{})
+	keys := make([]string, t.NumField())
+	for i := range keys {
+		keys[i], _, _ = strings.Cut(t.Field(i).Tag.Get("json"), ",")
+	}
+	return keys
+}
+
+func configField(config *Config, key string) (reflect.Value, bool) {
+	for i, k := range configKeys() {
+		if k == key {
+			return reflect.ValueOf(config).Elem().Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// configEnv names the environment variable that overrides key, so
+// maxFileMB is TODO_MAX_FILE_MB.
+func configEnv(key string) string {
+	var name strings.Builder
+	name.WriteString("TODO_")
+	for i, r := range key {
+		if unicode.IsUpper(r) && i > 0 && unicode.IsLower(rune(key[i-1])) {
+			name.WriteByte('_')
+		}
+		name.WriteRune(unicode.ToUpper(r))
+	}
+	return name.String()
+}
+
+func setConfigValue(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("expected true or false")
+		}
+		field.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("expected a whole number")
+		}
+		field.SetInt(int64(n))
+	default:
+		field.SetString(value)
+	}
+	return nil
+}
+
+// applyEnv lets environment variables override the config file; flags are
+// applied after it by main. Overridden keys keep their file values when
+// the config is saved, so the environment never leaks into config.json.
+func (app *TodoApp) applyEnv() {
+	app.fileConfig = app.config
+	app.envKeys = make(map[string]bool)
+	for _, key := range configKeys() {
+		value, ok := os.LookupEnv(configEnv(key))
+		if !ok {
+			continue
+		}
+		field, _ := configField(&app.config, key)
+		if err := setConfigValue(field, value); err != nil {
+			fmt.Fprintf(app.out, "Ignoring %s: %v.\n", configEnv(key), err)
+			continue
+		}
+		app.envKeys[key] = true
+	}
+}
+
+func formatConfigValue(field reflect.Value) string {
+	if field.Kind() == reflect.String {
+		return strconv.Quote(field.String())
+	}
+	return fmt.Sprint(field.Interface())
+}
+
+// configCommand shows or edits settings by key.
+func (app *TodoApp) configCommand(args string) error {
+	const usage = "config [get <key> | set <key> <value>]"
+	fields := strings.Fields(args)
+	switch {
+	case len(fields) == 0:
+		for _, key := range configKeys() {
+			field, _ := configField(&app.config, key)
+			source := ""
+			if app.envKeys[key] {
+				source = " (from " + configEnv(key) + ")"
+			}
+			fmt.Fprintf(app.out, "%-19s %-10s %s%s\n", key, formatConfigValue(field), configDocs[key], source)
+		}
+		fmt.Fprintln(app.out, "Stored in", configFileName())
+	case fields[0] == "get" && len(fields) == 2:
+		field, ok := configField(&app.config, fields[1])
+		if !ok {
+			return usageError("Unknown key", fields[1], usage)
+		}
+		fmt.Fprintln(app.out, formatConfigValue(field))
+	case fields[0] == "set" && len(fields) >= 2:
+		key := fields[1]
+		field, ok := configField(&app.config, key)
+		if !ok {
+			return usageError("Unknown key", key, usage)
+		}
+		value := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(args, "set")), key))
+		if err := setConfigValue(field, value); err != nil {
+			return fmt.Errorf("Can't set %s: %v.", key, err)
+		}
+		saved, _ := configField(&app.fileConfig, key)
+		saved.Set(field)
+		app.saveConfig()
+		fmt.Fprintf(app.out, "%s = %s\n", key, formatConfigValue(field))
+		if app.envKeys[key] {
+			fmt.Fprintf(app.out, "Note: %s is set, and overrides this at the next start.\n", configEnv(key))
+		}
+	default:
+		return usageError("Unknown option", args, usage)
+	}
+	return nil
+}
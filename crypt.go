@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Encrypted files are the magic header, a salt for the key derivation, a
+// GCM nonce and then the sealed JSON.
+const (
+	passphraseEnv = "TODO_PASSPHRASE"
+	saltSize      = 16
+	kdfIterations = 200000
+)
+
+var encryptedMagic = []byte("TODOENC1")
+
+var errWrongPassphrase = errors.New("wrong passphrase, or the file is damaged")
+
+func isEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, encryptedMagic)
+}
+
+// cipherFor derives the key for salt, reusing the last one so that saving
+// after every command doesn't pay for the derivation each time.
+func (app *TodoApp) cipherFor(salt []byte) (cipher.AEAD, error) {
+	if app.key == nil || !bytes.Equal(app.salt, salt) {
+		key, err := pbkdf2.Key(sha256.New, app.passphrase, salt, kdfIterations, 32)
+		if err != nil {
+			return nil, err
+		}
+		app.key, app.salt = key, salt
+	}
+	block, err := aes.NewCipher(app.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealData encrypts plain when a passphrase is set and returns it as is
+// otherwise.
+func (app *TodoApp) sealData(plain []byte) ([]byte, error) {
+	if app.passphrase == "" {
+		return plain, nil
+	}
+	salt := app.salt
+	if salt == nil {
+		salt = make([]byte, saltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+	}
+	aead, err := app.cipherFor(salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := append(append(append([]byte(nil), encryptedMagic...), salt...), nonce...)
+	return aead.Seal(sealed, nonce, plain, encryptedMagic), nil
+}
+
+// openData decrypts data read from fileName if it is encrypted, asking for
+// the passphrase at a terminal when none was given.
+func (app *TodoApp) openData(fileName string, data []byte) ([]byte, error) {
+	if !isEncrypted(data) {
+		return data, nil
+	}
+	if app.passphrase == "" {
+		if !app.interactive() {
+			return nil, fmt.Errorf("%s is encrypted; set %s to open it", fileName, passphraseEnv)
+		}
+		app.passphrase = app.readSecret(fmt.Sprintf("Passphrase for %s: ", fileName))
+	}
+	rest := data[len(encryptedMagic):]
+	if len(rest) < saltSize {
+		return nil, errWrongPassphrase
+	}
+	aead, err := app.cipherFor(rest[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+	rest = rest[saltSize:]
+	if len(rest) < aead.NonceSize() {
+		return nil, errWrongPassphrase
+	}
+	plain, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], encryptedMagic)
+	if err != nil {
+		return nil, errWrongPassphrase
+	}
+	return plain, nil
+}
+
+// readSecret reads a line without echoing it when stty is available.
+func (app *TodoApp) readSecret(prompt string) string {
+	fmt.Fprint(app.out, prompt)
+	stty := func(arg string) error {
+		cmd := exec.Command("stty", arg)
+		cmd.Stdin = os.Stdin
+		return cmd.Run()
+	}
+	if stty("-echo") == nil {
+		defer stty("echo")
+	}
+	scanner := app.input()
+	scanner.Scan()
+	fmt.Fprintln(app.out)
+	return strings.TrimSpace(scanner.Text())
+}
+
+// removePlainCopies deletes the undo journal and any unencrypted backups,
+// which would otherwise keep readable copies of an encrypted list. It
+// covers a passphrase given through the environment as well as encrypt.
+func (app *TodoApp) removePlainCopies() error {
+	if err := os.Remove(app.journalFileName()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return app.removePlainBackups()
+}
+
+// setEncryption turns encryption of the tasks file on or off. The
+// passphrase only lives for this session.
+func (app *TodoApp) setEncryption(enable bool) error {
+	if !enable {
+		if app.passphrase == "" {
+			return errors.New("The tasks file isn't encrypted.")
+		}
+		app.passphrase, app.key, app.salt = "", nil, nil
+		app.dirty = true
+		fmt.Fprintf(app.out, "%s will be saved unencrypted. Unset %s too, or it will be encrypted again next time.\n", app.fileName, passphraseEnv)
+		return nil
+	}
+	passphrase := app.readSecret("New passphrase: ")
+	if passphrase == "" {
+		return errors.New("Cancelled; no passphrase given.")
+	}
+	if app.readSecret("Repeat passphrase: ") != passphrase {
+		return errors.New("Passphrases don't match.")
+	}
+	app.passphrase, app.key, app.salt = passphrase, nil, nil
+	app.dirty = true
+	fmt.Fprintf(app.out, "%s will be saved encrypted. Set %s or enter the passphrase when asked at startup.\n", app.fileName, passphraseEnv)
+	return nil
+}
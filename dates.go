@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	dateLayout     = "2006-01-02"
+	dateTimeLayout = "2006-01-02 15:04"
+)
+
+func dayOf(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+func today() time.Time {
+	return dayOf(time.Now())
+}
+
+var (
+	errInvalidDate    = errors.New("Invalid date. Use YYYY-MM-DD, \"today\" or \"tomorrow\".")
+	errInvalidDueDate = errors.New("Invalid due date. Use a date (YYYY-MM-DD, \"today\" or \"tomorrow\") optionally followed by HH:MM.")
+)
+
+// parseDate accepts an ISO date (2006-01-02) or one of the words
+// "today" and "tomorrow".
+func parseDate(s string) (time.Time, bool) {
+	switch strings.ToLower(s) {
+	case "today":
+		return today(), true
+	case "tomorrow":
+		return today().AddDate(0, 0, 1), true
+	}
+	date, err := time.ParseInLocation(dateLayout, s, time.Local)
+	return date, err == nil
+}
+
+// storedDate parses a date saved in a task. Empty or malformed values
+// report false.
+func storedDate(s string) (time.Time, bool) {
+	if s == "" {
+		return time.Time{}, false
+	}
+	date, err := time.ParseInLocation(dateLayout, s, time.Local)
+	return date, err == nil
+}
+
+// parseDue accepts anything parseDate does, optionally followed by a
+// 24-hour time. It returns the value in the form stored on tasks.
+func parseDue(s string) (string, bool) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return "", false
+	}
+	date, ok := parseDate(fields[0])
+	if !ok {
+		return "", false
+	}
+	if len(fields) == 1 {
+		return date.Format(dateLayout), true
+	}
+	clock, err := time.Parse("15:04", fields[1])
+	if err != nil {
+		return "", false
+	}
+	due := date.Add(time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute)
+	return due.Format(dateTimeLayout), true
+}
+
+// storedDue parses a due date saved in a task. Date-only values fall due
+// at the start of the day.
+func storedDue(s string) (time.Time, bool) {
+	if due, err := time.ParseInLocation(dateTimeLayout, s, time.Local); err == nil {
+		return due, true
+	}
+	return storedDate(s)
+}
+
+// displayLayout turns a user pattern such as "DD.MM.YYYY" into a time
+// layout. Storage always uses ISO dates regardless of this setting.
+func displayLayout(pattern string) (string, bool) {
+	if !strings.Contains(pattern, "YY") || !strings.Contains(pattern, "MM") || !strings.Contains(pattern, "DD") {
+		return "", false
+	}
+	layout := strings.NewReplacer("YYYY", "2006", "YY", "06", "MM", "01", "DD", "02").Replace(pattern)
+	return layout, true
+}
+
+func (app *TodoApp) formatDate(stored string) string {
+	layout, ok := displayLayout(app.config.DateFormat)
+	if !ok {
+		return stored
+	}
+	if when, err := time.ParseInLocation(dateTimeLayout, stored, time.Local); err == nil {
+		return when.Format(layout + " 15:04")
+	}
+	if when, ok := storedDate(stored); ok {
+		return when.Format(layout)
+	}
+	return stored
+}
+
+func (app *TodoApp) setDateFormat(pattern string) error {
+	switch strings.ToLower(pattern) {
+	case "":
+		return usageError("", "", "datefmt <pattern|iso>")
+	case "iso":
+		app.config.DateFormat = ""
+	default:
+		if _, ok := displayLayout(pattern); !ok {
+			return usageError("Pattern needs YYYY (or YY), MM and DD", pattern, "datefmt <pattern|iso>")
+		}
+		app.config.DateFormat = pattern
+	}
+	app.saveConfig()
+	fmt.Fprintln(app.out, "Dates now display like", app.formatDate(today().Format(dateLayout)))
+	return nil
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// taskIndexByID returns the index of the task with the given ID, or -1.
+func (app *TodoApp) taskIndexByID(id int) int {
+	if app.cache != nil {
+		if index, ok := app.cache.indexByID[id]; ok {
+			return index
+		}
+		return -1
+	}
+	for i, task := range app.tasks {
+		if task.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// openBlockers returns the indices of the unfinished tasks this one waits
+// on. Blockers that have since been removed are ignored.
+func (app *TodoApp) openBlockers(task Task) []int {
+	var blockers []int
+	for _, id := range task.BlockedBy {
+		if index := app.taskIndexByID(id); index >= 0 && app.tasks[index].isOpen() {
+			blockers = append(blockers, index)
+		}
+	}
+	return blockers
+}
+
+// dropRemovedBlockers forgets dependencies on tasks that are no longer in
+// the list.
+func (app *TodoApp) dropRemovedBlockers() {
+	present := make(map[int]bool, len(app.tasks))
+	for _, task := range app.tasks {
+		present[task.ID] = true
+	}
+	for i := range app.tasks {
+		task := &app.tasks[i]
+		var kept []int
+		for _, id := range task.BlockedBy {
+			if present[id] {
+				kept = append(kept, id)
+			}
+		}
+		if len(kept) != len(task.BlockedBy) {
+			task.BlockedBy = kept
+			app.dirty = true
+		}
+	}
+}
+
+// blockTask records that the task at index can't start until the one at
+// blocker is finished. Dependencies are stored by ID so they survive
+// reordering.
+func (app *TodoApp) blockTask(index, blocker int) error {
+	if index < 0 || index >= len(app.tasks) {
+		return app.invalidTaskNumber(index)
+	}
+	if blocker < 0 || blocker >= len(app.tasks) {
+		return app.invalidTaskNumber(blocker)
+	}
+	if index == blocker {
+		return fmt.Errorf("Task %d can't block itself.", app.number(index))
+	}
+	task := &app.tasks[index]
+	blockerID := app.tasks[blocker].ID
+	for _, id := range task.BlockedBy {
+		if id == blockerID {
+			return fmt.Errorf("Task %d already blocks task %d.", app.number(blocker), app.number(index))
+		}
+	}
+	if path := app.dependencyPath(blocker, index); path != nil {
+		numbers := []string{strconv.Itoa(app.number(index))}
+		for _, i := range path {
+			numbers = append(numbers, strconv.Itoa(app.number(i)))
+		}
+		return fmt.Errorf("Not blocking: that would make a cycle, %s.", strings.Join(numbers, " waits on "))
+	}
+	task.BlockedBy = append(task.BlockedBy, blockerID)
+	app.dirty = true
+	fmt.Fprintf(app.out, "\"%s\" now waits on \"%s\".\n", task.Description, app.tasks[blocker].Description)
+	app.relist()
+	return nil
+}
+
+// dependencyPath returns the chain of task indices from one task to
+// another that it waits on, directly or through other tasks, starting with
+// from and ending with to. It returns nil if there is no such chain.
+func (app *TodoApp) dependencyPath(from, to int) []int {
+	visited := make(map[int]bool)
+	var search func(index int) []int
+	search = func(index int) []int {
+		if index == to {
+			return []int{to}
+		}
+		visited[index] = true
+		for _, id := range app.tasks[index].BlockedBy {
+			next := app.taskIndexByID(id)
+			if next < 0 || visited[next] {
+				continue
+			}
+			if path := search(next); path != nil {
+				return append([]int{index}, path...)
+			}
+		}
+		return nil
+	}
+	return search(from)
+}
+
+func (app *TodoApp) unblockTask(index, blocker int) error {
+	if index < 0 || index >= len(app.tasks) {
+		return app.invalidTaskNumber(index)
+	}
+	if blocker < 0 || blocker >= len(app.tasks) {
+		return app.invalidTaskNumber(blocker)
+	}
+	task := &app.tasks[index]
+	blockerID := app.tasks[blocker].ID
+	for i, id := range task.BlockedBy {
+		if id == blockerID {
+			task.BlockedBy = append(task.BlockedBy[:i], task.BlockedBy[i+1:]...)
+			app.dirty = true
+			fmt.Fprintf(app.out, "\"%s\" no longer waits on \"%s\".\n", task.Description, app.tasks[blocker].Description)
+			app.relist()
+			return nil
+		}
+	}
+	return fmt.Errorf("Task %d doesn't block task %d.", app.number(blocker), app.number(index))
+}
+
+// showNext prints the open task to do next: the highest priority one that
+// nothing unfinished blocks, then the earliest due, then the first listed.
+// Someday and not-yet-started tasks are skipped.
+func (app *TodoApp) showNext() {
+	now := today()
+	best := -1
+	blocked := make(map[int]bool)
+	for i, task := range app.tasks {
+		if !task.isOpen() || task.isHidden(now) {
+			continue
+		}
+		if blockers := app.openBlockers(task); len(blockers) > 0 {
+			for _, blocker := range blockers {
+				blocked[blocker] = true
+			}
+			continue
+		}
+		if best < 0 || app.comesBefore(task, app.tasks[best]) {
+			best = i
+		}
+	}
+	switch {
+	case best >= 0:
+		app.printTask(best, app.tasks[best])
+	case len(blocked) > 0:
+		fmt.Fprintln(app.out, "Every open task is blocked. These are holding things up:")
+		for i, task := range app.tasks {
+			if blocked[i] {
+				app.printTask(i, task)
+			}
+		}
+	default:
+		fmt.Fprintln(app.out, "Nothing to do!")
+	}
+}
+
+func (app *TodoApp) comesBefore(a, b Task) bool {
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	aDue, aOK := storedDue(a.Due)
+	bDue, bOK := storedDue(b.Due)
+	if aOK != bOK {
+		return aOK
+	}
+	return aOK && aDue.Before(bDue)
+}
+
+// showDependencies prints the task with everything it waits on indented
+// beneath it. A task that leads back to one already on the path is marked
+// as a cycle instead of being followed again.
+func (app *TodoApp) showDependencies(index int) error {
+	if index < 0 || index >= len(app.tasks) {
+		return app.invalidTaskNumber(index)
+	}
+	cycles := 0
+	onPath := make(map[int]bool)
+	var walk func(index, depth int)
+	walk = func(index, depth int) {
+		task := app.tasks[index]
+		fmt.Fprintf(app.out, "%s%d. %s %s\n", strings.Repeat("    ", depth), app.number(index), statusMark(task), task.Description)
+		onPath[task.ID] = true
+		for _, id := range task.BlockedBy {
+			blocker := app.taskIndexByID(id)
+			if blocker < 0 {
+				continue
+			}
+			if onPath[id] {
+				cycles++
+				fmt.Fprintf(app.out, "%s%d. %s (cycle)\n", strings.Repeat("    ", depth+1), app.number(blocker), app.tasks[blocker].Description)
+				continue
+			}
+			walk(blocker, depth+1)
+		}
+		delete(onPath, task.ID)
+	}
+	walk(index, 0)
+	if len(app.tasks[index].BlockedBy) == 0 {
+		fmt.Fprintln(app.out, "Nothing blocks this task.")
+	}
+	if cycles > 0 {
+		fmt.Fprintln(app.out, "Warning: these dependencies form a cycle. Use unblock to break it.")
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import "testing"
+
+func TestBlockTaskRefusesCycles(t *testing.T) {
+	tests := []struct {
+		name    string
+		blocks  [][2]int
+		index   int
+		blocker int
+		want    string
+	}{
+		{
+			name:    "direct",
+			blocks:  [][2]int{{0, 1}},
+			index:   1,
+			blocker: 0,
+			want:    "Not blocking: that would make a cycle, 2 waits on 1 waits on 2.",
+		},
+		{
+			name:    "transitive",
+			blocks:  [][2]int{{0, 1}, {1, 2}},
+			index:   2,
+			blocker: 0,
+			want:    "Not blocking: that would make a cycle, 3 waits on 1 waits on 2 waits on 3.",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app, _ := newTestApp(t, "A", "B", "C")
+			for _, block := range test.blocks {
+				if err := app.blockTask(block[0], block[1]); err != nil {
+					t.Fatalf("blockTask(%d, %d): %v", block[0], block[1], err)
+				}
+			}
+			err := app.blockTask(test.index, test.blocker)
+			if err == nil || err.Error() != test.want {
+				t.Fatalf("blockTask(%d, %d) = %v, want %q", test.index, test.blocker, err, test.want)
+			}
+			if len(app.tasks[test.index].BlockedBy) != 0 {
+				t.Errorf("task %d left blocked by %v", test.index+1, app.tasks[test.index].BlockedBy)
+			}
+		})
+	}
+}
+
+func TestBlockTaskAllowsChains(t *testing.T) {
+	app, _ := newTestApp(t, "A", "B", "C")
+	if err := app.blockTask(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.blockTask(1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.blockTask(0, 2); err != nil {
+		t.Fatalf("blocking A on C as well as B: %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// showDigest prints plain text for pasting into an email: open tasks
+// grouped by assignee, or by tag when nobody is assigned anything, then
+// the tasks completed since the start of the week.
+func (app *TodoApp) showDigest(args string) error {
+	if args != "" {
+		return usageError("Unknown option", args, "digest")
+	}
+	now := today()
+	var open []Task
+	byAssignee := false
+	for _, task := range app.tasks {
+		if task.isOpen() && !task.isHidden(now) {
+			open = append(open, task)
+			byAssignee = byAssignee || task.Assignee != ""
+		}
+	}
+
+	groups := make(map[string][]Task)
+	var rest []Task
+	for _, task := range open {
+		switch {
+		case byAssignee && task.Assignee != "":
+			groups["@"+task.Assignee] = append(groups["@"+task.Assignee], task)
+		case !byAssignee && len(task.Tags) > 0:
+			for _, tag := range task.Tags {
+				groups["#"+tag] = append(groups["#"+tag], task)
+			}
+		default:
+			rest = append(rest, task)
+		}
+	}
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(app.out, "Open tasks (%d)\n", len(open))
+	for _, name := range names {
+		app.printDigestGroup(name, groups[name])
+	}
+	if len(rest) > 0 {
+		other := "Untagged"
+		if byAssignee {
+			other = "Unassigned"
+		}
+		app.printDigestGroup(other, rest)
+	}
+
+	archived, err := app.loadArchive()
+	if err != nil {
+		return err
+	}
+	weekStart := now.AddDate(0, 0, -int(now.Weekday()))
+	var done []Task
+	for _, task := range append(archived, app.tasks...) {
+		if task.IsCompleted && !task.CompletedAt.IsZero() && !task.CompletedAt.Local().Before(weekStart) {
+			done = append(done, task)
+		}
+	}
+	sort.SliceStable(done, func(i, j int) bool {
+		return done[i].CompletedAt.Before(done[j].CompletedAt)
+	})
+	fmt.Fprintf(app.out, "\nCompleted this week (%d)\n", len(done))
+	for _, task := range done {
+		line := "- " + task.Description
+		if task.Assignee != "" {
+			line += " @" + task.Assignee
+		}
+		fmt.Fprintf(app.out, "%s (%s)\n", line, app.formatDate(task.CompletedAt.Local().Format(dateLayout)))
+	}
+	return nil
+}
+
+func (app *TodoApp) printDigestGroup(name string, tasks []Task) {
+	fmt.Fprintf(app.out, "\n%s\n", name)
+	for _, task := range tasks {
+		line := "- " + task.Description
+		if task.Priority > 0 {
+			line += " [" + priorityNames[task.Priority] + "]"
+		}
+		if task.Due != "" {
+			line += " (due " + app.formatDate(task.Due) + ")"
+		}
+		fmt.Fprintln(app.out, line)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Event struct {
+	Time   time.Time `json:"time"`
+	TaskID int       `json:"taskId"`
+	Type   string    `json:"type"`
+}
+
+func (app *TodoApp) eventsFileName() string {
+	ext := filepath.Ext(app.fileName)
+	return strings.TrimSuffix(app.fileName, ext) + ".events.json"
+}
+
+func (app *TodoApp) loadEvents() {
+	app.events = nil
+	data, err := ioutil.ReadFile(app.eventsFileName())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Fprintln(app.out, "Error reading events:", err)
+		}
+		return
+	}
+	if err := json.Unmarshal(data, &app.events); err != nil {
+		fmt.Fprintln(app.out, "Error parsing events:", err)
+	}
+}
+
+func (app *TodoApp) saveEvents() {
+	data, err := json.Marshal(app.events)
+	if err != nil {
+		fmt.Fprintln(app.out, "Error encoding events:", err)
+		return
+	}
+	if err := writeFileAtomic(app.eventsFileName(), data, 0644); err != nil {
+		fmt.Fprintln(app.out, "Error writing events:", err)
+		return
+	}
+	app.eventsDirty = false
+}
+
+func (app *TodoApp) logEvent(eventType string, task Task) {
+	if !app.config.Streaks {
+		return
+	}
+	app.events = append(app.events, Event{Time: time.Now(), TaskID: task.ID, Type: eventType})
+	app.eventsDirty = true
+}
+
+// completionStreaks counts consecutive days with at least one completion.
+// The current streak is still alive if the last completion was yesterday.
+func completionStreaks(events []Event, now time.Time) (current, longest int) {
+	days := make(map[time.Time]bool)
+	for _, event := range events {
+		if event.Type == "complete" {
+			days[dayOf(event.Time.In(now.Location()))] = true
+		}
+	}
+
+	sorted := make([]time.Time, 0, len(days))
+	for day := range days {
+		sorted = append(sorted, day)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+	run := 0
+	for i, day := range sorted {
+		if i > 0 && sorted[i-1].AddDate(0, 0, 1).Equal(day) {
+			run++
+		} else {
+			run = 1
+		}
+		if run > longest {
+			longest = run
+		}
+	}
+
+	day := dayOf(now)
+	if !days[day] {
+		day = day.AddDate(0, 0, -1)
+	}
+	for days[day] {
+		current++
+		day = day.AddDate(0, 0, -1)
+	}
+	return current, longest
+}
+
+func (app *TodoApp) showStreak(arg string) error {
+	switch arg {
+	case "on":
+		app.config.Streaks = true
+		app.saveConfig()
+		app.loadEvents()
+		fmt.Fprintln(app.out, "Streak tracking enabled.")
+	case "off":
+		app.config.Streaks = false
+		app.saveConfig()
+		fmt.Fprintln(app.out, "Streak tracking disabled.")
+	case "":
+		if !app.config.Streaks {
+			fmt.Fprintln(app.out, "Streak tracking is off. Type \"streak on\" to enable it.")
+			return nil
+		}
+		current, longest := completionStreaks(app.events, time.Now())
+		fmt.Fprintf(app.out, "Current streak: %d day(s)\n", current)
+		fmt.Fprintf(app.out, "Longest streak: %d day(s)\n", longest)
+	default:
+		return usageError("Unknown option", arg, "streak [on|off]")
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultHeatmapWeeks = 8
+
+// heatmapCells go from no completions to four or more.
+var heatmapCells = []string{"·", "░", "▒", "▓", "█"}
+
+// completionsByDay counts completions per day. Streak events are used when
+// they are being recorded, since they include every occurrence of recurring
+// tasks; otherwise it falls back to the completion times on current and
+// archived tasks.
+func (app *TodoApp) completionsByDay() (map[time.Time]int, error) {
+	counts := make(map[time.Time]int)
+	if app.config.Streaks {
+		for _, event := range app.events {
+			if event.Type == "complete" {
+				counts[dayOf(event.Time.Local())]++
+			}
+		}
+		return counts, nil
+	}
+	archived, err := app.loadArchive()
+	if err != nil {
+		return nil, err
+	}
+	for _, task := range append(archived, app.tasks...) {
+		if task.IsCompleted && !task.CompletedAt.IsZero() {
+			counts[dayOf(task.CompletedAt.Local())]++
+		}
+	}
+	return counts, nil
+}
+
+// showHeatmap prints one column per week, oldest first, with a row for each
+// weekday. The final column is the current week.
+func (app *TodoApp) showHeatmap(arg string) error {
+	weeks := defaultHeatmapWeeks
+	if arg != "" {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 || n > 52 {
+			return usageError("Weeks must be 1-52", arg, "heatmap [weeks]")
+		}
+		weeks = n
+	}
+	counts, err := app.completionsByDay()
+	if err != nil {
+		return err
+	}
+
+	now := today()
+	start := now.AddDate(0, 0, -int(now.Weekday())-7*(weeks-1))
+	total := 0
+	fmt.Fprintln(app.out)
+	for weekday := 0; weekday < 7; weekday++ {
+		var row strings.Builder
+		row.WriteString(time.Weekday(weekday).String()[:2])
+		for week := 0; week < weeks; week++ {
+			day := start.AddDate(0, 0, 7*week+weekday)
+			row.WriteString(" ")
+			if day.After(now) {
+				row.WriteString(" ")
+				continue
+			}
+			count := counts[day]
+			total += count
+			row.WriteString(app.heatmapCell(count))
+		}
+		fmt.Fprintln(app.out, strings.TrimRight(row.String(), " "))
+	}
+	fmt.Fprintln(app.out)
+	fmt.Fprintf(app.out, "Less %s More. %d completed in the last %d weeks.\n", strings.Join(heatmapCells, " "), total, weeks)
+	return nil
+}
+
+func (app *TodoApp) heatmapCell(count int) string {
+	level := min(count, len(heatmapCells)-1)
+	if level == 0 {
+		return heatmapCells[0]
+	}
+	return app.colorize(colorGreen, heatmapCells[level])
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// parseChecklistLine reads a Markdown checklist item such as "- [x] buy milk".
+// ok is false for lines that aren't checklist items; malformed is true for
+// lines that look like one but can't be read.
+func parseChecklistLine(line string) (task Task, ok, malformed bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "- [") && !strings.HasPrefix(line, "* [") {
+		return Task{}, false, false
+	}
+	rest := line[3:]
+	if len(rest) < 2 || rest[1] != ']' {
+		return Task{}, false, true
+	}
+	switch rest[0] {
+	case ' ':
+	case 'x', 'X':
+		task.IsCompleted = true
+	default:
+		return Task{}, false, true
+	}
+	task.Description = strings.TrimSpace(rest[2:])
+	if task.Description == "" {
+		return Task{}, false, true
+	}
+	return task, true, false
+}
+
+func (app *TodoApp) importMarkdown(fileName string) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return fmt.Errorf("Error reading file: %v", err)
+	}
+	defer file.Close()
+
+	imported, skipped := 0, 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		task, ok, malformed := parseChecklistLine(scanner.Text())
+		if malformed {
+			skipped++
+		}
+		if !ok {
+			continue
+		}
+		newTask := app.taskFromText(task.Description)
+		newTask.IsCompleted = task.IsCompleted
+		newTask.Source = fileName
+		if err = app.checkOpenLimit(newTask); err != nil {
+			break
+		}
+		app.tasks = append(app.tasks, newTask)
+		imported++
+	}
+	if imported > 0 {
+		app.dirty = true
+	}
+	if err != nil {
+		fmt.Fprintf(app.out, "Imported %d task(s) before stopping.\n", imported)
+		app.relist()
+		return err
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("Error reading file: %v", err)
+	}
+	if skipped > 0 {
+		fmt.Fprintf(app.out, "Imported %d task(s), skipped %d malformed line(s).\n", imported, skipped)
+	} else {
+		fmt.Fprintf(app.out, "Imported %d task(s).\n", imported)
+	}
+	app.relist()
+	app.reportOpenLimit()
+	return nil
+}
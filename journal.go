@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const defaultUndoDepth = 50
+
+// JournalEntry records how to reverse one command: the task order before
+// it ran and the previous version of every task it changed or removed.
+// Tasks it added are dropped on undo because their IDs aren't in Order.
+type JournalEntry struct {
+	Time    time.Time `json:"time"`
+	Command string    `json:"command"`
+	Order   []int     `json:"order"`
+	Before  []Task    `json:"before,omitempty"`
+}
+
+func (app *TodoApp) journalFileName() string {
+	ext := filepath.Ext(app.fileName)
+	return strings.TrimSuffix(app.fileName, ext) + ".journal"
+}
+
+func (app *TodoApp) undoDepth() int {
+	if app.config.UndoDepth > 0 {
+		return app.config.UndoDepth
+	}
+	return defaultUndoDepth
+}
+
+func (app *TodoApp) loadJournal() {
+	app.journal = nil
+	file, err := os.Open(app.journalFileName())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Fprintln(app.out, "Error reading journal:", err)
+		}
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 64*1024*1024)
+	for scanner.Scan() {
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			fmt.Fprintln(app.out, "Error parsing journal:", err)
+			continue
+		}
+		app.journal = append(app.journal, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(app.out, "Error reading journal:", err)
+	}
+}
+
+// The journal holds copies of tasks, so it stays in memory only while the
+// tasks file is encrypted.
+func (app *TodoApp) rewriteJournal() {
+	if app.passphrase != "" {
+		return
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, entry := range app.journal {
+		if err := encoder.Encode(entry); err != nil {
+			fmt.Fprintln(app.out, "Error encoding journal:", err)
+			return
+		}
+	}
+	if err := writeFileAtomic(app.journalFileName(), buf.Bytes(), 0644); err != nil {
+		fmt.Fprintln(app.out, "Error writing journal:", err)
+	}
+}
+
+func (app *TodoApp) appendJournal(entry JournalEntry) {
+	app.journal = append(app.journal, entry)
+	if len(app.journal) > app.undoDepth() {
+		app.journal = app.journal[len(app.journal)-app.undoDepth():]
+		app.rewriteJournal()
+		return
+	}
+	if app.passphrase != "" {
+		return
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		fmt.Fprintln(app.out, "Error encoding journal:", err)
+		return
+	}
+	file, err := os.OpenFile(app.journalFileName(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		fmt.Fprintln(app.out, "Error writing journal:", err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		fmt.Fprintln(app.out, "Error writing journal:", err)
+	}
+}
+
+func cloneTasks(tasks []Task) []Task {
+	data, err := json.Marshal(tasks)
+	if err != nil {
+		return append([]Task(nil), tasks...)
+	}
+	var clone []Task
+	json.Unmarshal(data, &clone)
+	return clone
+}
+
+// newJournalEntry reports false when before and after are the same list.
+func newJournalEntry(command string, before, after []Task) (JournalEntry, bool) {
+	entry := JournalEntry{Time: time.Now(), Command: command}
+	afterByID := make(map[int]Task)
+	for _, task := range after {
+		afterByID[task.ID] = task
+	}
+	for _, task := range before {
+		entry.Order = append(entry.Order, task.ID)
+		if current, ok := afterByID[task.ID]; !ok || !reflect.DeepEqual(current, task) {
+			entry.Before = append(entry.Before, task)
+		}
+	}
+
+	reordered := len(before) != len(after)
+	for i := 0; !reordered && i < len(after); i++ {
+		reordered = after[i].ID != before[i].ID
+	}
+	return entry, reordered || len(entry.Before) > 0
+}
+
+func (entry JournalEntry) revert(current []Task) []Task {
+	byID := make(map[int]Task)
+	for _, task := range current {
+		byID[task.ID] = task
+	}
+	for _, task := range entry.Before {
+		byID[task.ID] = task
+	}
+	restored := make([]Task, 0, len(entry.Order))
+	for _, id := range entry.Order {
+		if task, ok := byID[id]; ok {
+			restored = append(restored, task)
+		}
+	}
+	return restored
+}
+
+func (app *TodoApp) undo() error {
+	if len(app.journal) == 0 {
+		return errors.New("Nothing to undo.")
+	}
+	entry := app.journal[len(app.journal)-1]
+	app.journal = app.journal[:len(app.journal)-1]
+	app.rewriteJournal()
+	app.tasks = entry.revert(app.tasks)
+	app.dirty = true
+	app.skipJournal = true
+	fmt.Fprintf(app.out, "Undid \"%s\".\n", entry.Command)
+	app.relist()
+	return nil
+}
+
+// showHistory lists undoable commands, the one undo reverts next first.
+func (app *TodoApp) showHistory(arg string) error {
+	switch arg {
+	case "clear":
+		app.journal = nil
+		app.rewriteJournal()
+		fmt.Fprintln(app.out, "Undo history cleared.")
+		return nil
+	case "":
+	default:
+		return usageError("Unknown option", arg, "history [clear]")
+	}
+	if len(app.journal) == 0 {
+		fmt.Fprintln(app.out, "Nothing to undo.")
+		return nil
+	}
+	for i := len(app.journal) - 1; i >= 0; i-- {
+		entry := app.journal[i]
+		summary := "tasks added or reordered"
+		if len(entry.Before) > 0 {
+			summary = fmt.Sprintf("%d task(s) changed or removed", len(entry.Before))
+		}
+		fmt.Fprintf(app.out, "%d. %s  %s (%s)\n", len(app.journal)-i, entry.Time.Local().Format(dateTimeLayout), entry.Command, summary)
+	}
+	return nil
+}
+
+// execute runs one command, prints any failure, and journals whatever it
+// changed so it can be undone, even in a later session.
+func (app *TodoApp) execute(command string) error {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	before := cloneTasks(app.tasks)
+	app.skipJournal = false
+	err := app.processCommand(command)
+	if err != nil {
+		fmt.Fprintln(app.errOut, err)
+	}
+	app.dropRemovedBlockers()
+	if app.dirty && !app.skipJournal {
+		if entry, changed := newJournalEntry(command, before, cloneTasks(app.tasks)); changed {
+			app.appendJournal(entry)
+		}
+	}
+	if flushErr := app.flush(); flushErr != nil {
+		fmt.Fprintln(app.errOut, flushErr)
+		return flushErr
+	}
+	return err
+}
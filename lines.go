@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+)
+
+// encodeLines writes one task per line, the layout that lets new tasks be
+// appended without rewriting the file.
+func encodeLines(tasks []Task) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, task := range tasks {
+		line, err := json.Marshal(task)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeTasks reads a JSON array of tasks or one task per line. In the line
+// layout a task written again later replaces its earlier line, and a last
+// line cut short by an interrupted append is skipped and reported as torn.
+func decodeTasks(data []byte) (tasks []Task, lines int, torn bool, err error) {
+	if isArray(data) {
+		err = json.Unmarshal(data, &tasks)
+		return tasks, 0, false, err
+	}
+	rows := bytes.Split(bytes.TrimRight(data, " \t\r\n"), []byte("\n"))
+	indexByID := make(map[int]int)
+	for i, row := range rows {
+		if len(bytes.TrimSpace(row)) == 0 {
+			continue
+		}
+		var task Task
+		if err := json.Unmarshal(row, &task); err != nil {
+			if i == len(rows)-1 && !bytes.HasSuffix(data, []byte("\n")) {
+				return tasks, lines, true, nil
+			}
+			return nil, 0, false, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		lines++
+		if index, ok := indexByID[task.ID]; ok && task.ID != 0 {
+			tasks[index] = task
+			continue
+		}
+		indexByID[task.ID] = len(tasks)
+		tasks = append(tasks, task)
+	}
+	return tasks, lines, false, nil
+}
+
+func isArray(data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+// rememberSaved records what is on disk when it is in the line layout, for
+// appendChanges to compare against. Other layouts are always rewritten.
+func (app *TodoApp) rememberSaved(lines bool, lineCount int) {
+	app.saved, app.savedLines = nil, 0
+	if lines && app.passphrase == "" {
+		app.saved = cloneTasks(app.tasks)
+		app.savedLines = lineCount
+	}
+}
+
+// appendChanges saves by appending a line for each task added or changed
+// since the file was last read or written. Rewritten tasks replace their
+// earlier lines when the file is read back. It reports false, leaving the
+// save to a full rewrite, when tasks were removed or reordered, or when
+// superseded lines would outnumber the tasks. Appending never touches
+// existing bytes, so no backup is taken.
+func (app *TodoApp) appendChanges() (bool, error) {
+	if !app.config.JSONLines || len(app.saved) == 0 || len(app.tasks) < len(app.saved) {
+		return false, nil
+	}
+	current := cloneTasks(app.tasks)
+	var changed []Task
+	for i, task := range current {
+		if i >= len(app.saved) {
+			changed = append(changed, task)
+			continue
+		}
+		if task.ID != app.saved[i].ID {
+			return false, nil
+		}
+		if !reflect.DeepEqual(task, app.saved[i]) {
+			changed = append(changed, task)
+		}
+	}
+	if app.savedLines+len(changed)-len(current) > len(current) {
+		return false, nil
+	}
+	data, err := encodeLines(changed)
+	if err != nil {
+		return false, nil
+	}
+	file, err := os.OpenFile(app.fileName, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return false, nil
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return true, err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return true, err
+	}
+	app.savedLines += len(changed)
+	return true, file.Close()
+}
+
+// supersededLines counts lines in the file that a later line replaces.
+func (app *TodoApp) supersededLines() int {
+	return app.savedLines - len(app.saved)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os/exec"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+const dueCheckInterval = 30 * time.Second
+
+// watchDue fires a desktop notification for each open task whose due time
+// passes while the session is running. Tasks already due at start are left
+// to the listing.
+func (app *TodoApp) watchDue(since time.Time) {
+	notified := make(map[int]bool)
+	for now := range time.Tick(dueCheckInterval) {
+		app.mu.Lock()
+		var due []string
+		for _, task := range app.tasks {
+			dueTime, ok := storedDue(task.Due)
+			if !ok || !task.isOpen() || notified[task.ID] {
+				continue
+			}
+			if dueTime.After(since) && !dueTime.After(now) {
+				notified[task.ID] = true
+				due = append(due, task.Description)
+			}
+		}
+		app.mu.Unlock()
+
+		for _, description := range due {
+			sendNotification("Task due", description)
+		}
+	}
+}
+
+// sendNotification does nothing where no notifier is available.
+func sendNotification(title, message string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := "display notification " + strconv.Quote(message) + " with title " + strconv.Quote(title)
+		cmd = exec.Command("osascript", "-e", script)
+	case "linux", "freebsd", "openbsd", "netbsd":
+		if _, err := exec.LookPath("notify-send"); err != nil {
+			return
+		}
+		cmd = exec.Command("notify-send", title, message)
+	default:
+		return
+	}
+	cmd.Run()
+}
@@ -0,0 +1,57 @@
+package main
+
+import "fmt"
+
+// In relative numbering, the tasks the main listing shows are numbered 1..N
+// in order and hidden ones continue from N+1, so the number on screen is
+// always the one to type. Absolute numbering is each task's position.
+
+// numberOrder returns task indices in numbering order.
+func (app *TodoApp) numberOrder() []int {
+	now := today()
+	order := make([]int, 0, len(app.tasks))
+	for pass := 0; pass < 2; pass++ {
+		for i, task := range app.tasks {
+			if task.isHidden(now) == (pass == 1) {
+				order = append(order, i)
+			}
+		}
+	}
+	return order
+}
+
+// taskIndex converts a typed 1-based task number to an index. Numbers out
+// of range stay out of range, for the caller to report.
+func (app *TodoApp) taskIndex(number int) int {
+	if !app.config.RelativeNumbers || number < 1 || number > len(app.tasks) {
+		return number - 1
+	}
+	return app.numberOrder()[number-1]
+}
+
+// number is the task number to show for an index.
+func (app *TodoApp) number(index int) int {
+	if !app.config.RelativeNumbers || index < 0 || index >= len(app.tasks) {
+		return index + 1
+	}
+	if app.cache != nil && app.cache.numbers != nil {
+		return app.cache.numbers[index]
+	}
+	for n, i := range app.numberOrder() {
+		if i == index {
+			return n + 1
+		}
+	}
+	return index + 1
+}
+
+func (app *TodoApp) toggleRelativeNumbers() {
+	app.config.RelativeNumbers = !app.config.RelativeNumbers
+	app.saveConfig()
+	if app.config.RelativeNumbers {
+		fmt.Fprintln(app.out, "Task numbers now count the listed tasks; hidden ones follow.")
+	} else {
+		fmt.Fprintln(app.out, "Task numbers are now positions in the whole list.")
+	}
+	app.relist()
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+func validPluginName(name string) bool {
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// runPlugin looks for a program called todo-<name> on the PATH, the way git
+// finds subcommands, and runs it with args. The tasks file is saved first
+// and its path passed in TODO_FILE; if the program changes the file, the
+// list is reloaded. ok is false when there's no such program.
+func (app *TodoApp) runPlugin(name, args string) (ok bool, err error) {
+	if !validPluginName(name) {
+		return false, nil
+	}
+	path, err := exec.LookPath("todo-" + name)
+	if err != nil {
+		return false, nil
+	}
+	pluginArgs, err := splitArgs(args)
+	if err != nil {
+		return true, err
+	}
+	if err := app.flush(); err != nil {
+		return true, err
+	}
+	fileName, err := filepath.Abs(app.fileName)
+	if err != nil {
+		fileName = app.fileName
+	}
+	before, _ := os.Stat(app.fileName)
+
+	cmd := exec.Command(path, pluginArgs...)
+	cmd.Env = append(os.Environ(), "TODO_FILE="+fileName)
+	if app.interactive() {
+		cmd.Stdin = app.in
+	}
+	cmd.Stdout = app.out
+	cmd.Stderr = os.Stderr
+	runErr := cmd.Run()
+
+	if after, err := os.Stat(app.fileName); err == nil && (before == nil || !after.ModTime().Equal(before.ModTime()) || after.Size() != before.Size()) {
+		app.tasks = nil
+		app.loadTasks()
+		app.relist()
+	}
+	if runErr != nil {
+		return true, fmt.Errorf("todo-%s failed: %v", name, runErr)
+	}
+	return true, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// parseEstimate accepts a duration such as "45m" or "1h30m" and returns it
+// in whole minutes.
+func parseEstimate(s string) (int, bool) {
+	d, err := time.ParseDuration(s)
+	if err != nil || d < time.Minute {
+		return 0, false
+	}
+	return int(d / time.Minute), true
+}
+
+func formatEstimate(minutes int) string {
+	hours, minutes := minutes/60, minutes%60
+	switch {
+	case hours == 0:
+		return fmt.Sprintf("%dm", minutes)
+	case minutes == 0:
+		return fmt.Sprintf("%dh", hours)
+	}
+	return fmt.Sprintf("%dh%dm", hours, minutes)
+}
+
+func (app *TodoApp) setEstimate(index int, value string) error {
+	if index < 0 || index >= len(app.tasks) {
+		return app.invalidTaskNumber(index)
+	}
+	if strings.ToLower(value) == "none" {
+		app.tasks[index].Estimate = 0
+		app.dirty = true
+		fmt.Fprintln(app.out, "Estimate cleared.")
+		app.relist()
+		return nil
+	}
+	minutes, ok := parseEstimate(value)
+	if !ok {
+		return usageError("Not a duration like 45m or 1h30m", value, "estimate <task number> <duration|none>")
+	}
+	app.tasks[index].Estimate = minutes
+	app.dirty = true
+	fmt.Fprintln(app.out, "Estimate set to", formatEstimate(minutes))
+	app.relist()
+	return nil
+}
+
+// showProgress reports how much of the list is done. Weighted progress
+// counts each task by its estimate, with unestimated tasks weighing the
+// average estimate; with no estimates at all it falls back to counting.
+func (app *TodoApp) showProgress(arg string) error {
+	if arg != "" && arg != "-weighted" {
+		return usageError("Unknown option", arg, "progress [-weighted]")
+	}
+	var done, total, estimated, estimatedSum int
+	for _, task := range app.tasks {
+		if task.Status == statusCancelled {
+			continue
+		}
+		total++
+		if task.IsCompleted {
+			done++
+		}
+		if task.Estimate > 0 {
+			estimated++
+			estimatedSum += task.Estimate
+		}
+	}
+	if total == 0 {
+		fmt.Fprintln(app.out, "No tasks.")
+		return nil
+	}
+	fraction := float64(done) / float64(total)
+	summary := fmt.Sprintf("%d of %d tasks done", done, total)
+	if arg == "-weighted" {
+		if estimated == 0 {
+			summary += " (no estimates, counted equally)"
+		} else {
+			average := float64(estimatedSum) / float64(estimated)
+			var doneWeight, totalWeight float64
+			for _, task := range app.tasks {
+				if task.Status == statusCancelled {
+					continue
+				}
+				weight := average
+				if task.Estimate > 0 {
+					weight = float64(task.Estimate)
+				}
+				totalWeight += weight
+				if task.IsCompleted {
+					doneWeight += weight
+				}
+			}
+			fraction = doneWeight / totalWeight
+			summary += ", weighted by estimate"
+			if estimated < total {
+				summary += fmt.Sprintf(" (%d unestimated)", total-estimated)
+			}
+		}
+	}
+	const barWidth = 20
+	filled := int(fraction*barWidth + 0.5)
+	fmt.Fprintf(app.out, "[%s%s] %.0f%%\n", strings.Repeat("#", filled), strings.Repeat(".", barWidth-filled), fraction*100)
+	fmt.Fprintln(app.out, summary+".")
+	return nil
+}
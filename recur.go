@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var recurrences = []string{"daily", "weekly", "monthly"}
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// parseWeekdays reads a set of weekdays such as "mon,wed,fri" or
+// "monday friday" and returns it in the stored form, ordered Sunday first.
+func parseWeekdays(s string) (string, error) {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool { return r == ',' || r == ' ' })
+	if len(fields) == 0 {
+		return "", errors.New("No weekdays given.")
+	}
+	chosen := make([]bool, len(weekdayNames))
+	for _, field := range fields {
+		found := false
+		for i := range weekdayNames {
+			if len(field) >= 3 && strings.HasPrefix(strings.ToLower(time.Weekday(i).String()), field) {
+				chosen[i], found = true, true
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("Unknown repeat: %q. Use daily, weekly, monthly, none or weekdays like mon,wed,fri.", field)
+		}
+	}
+	var names []string
+	for i, name := range weekdayNames {
+		if chosen[i] {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ","), nil
+}
+
+func nextOccurrence(due time.Time, recur string) time.Time {
+	switch recur {
+	case "daily":
+		return due.AddDate(0, 0, 1)
+	case "weekly":
+		return due.AddDate(0, 0, 7)
+	case "monthly":
+		return due.AddDate(0, 1, 0)
+	}
+	next := due.AddDate(0, 0, 1)
+	for i := 0; i < 7 && !strings.Contains(recur, weekdayNames[next.Weekday()]); i++ {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// formatDueLike formats due the way stored was written, keeping the time
+// of day only if stored had one.
+func formatDueLike(stored string, due time.Time) string {
+	if len(stored) > len(dateLayout) {
+		return due.Format(dateTimeLayout)
+	}
+	return due.Format(dateLayout)
+}
+
+func (app *TodoApp) setRecurrence(index int, value string) error {
+	if index < 0 || index >= len(app.tasks) {
+		return app.invalidTaskNumber(index)
+	}
+	task := &app.tasks[index]
+	value = strings.ToLower(value)
+	if value == "none" {
+		task.Recur = ""
+		app.dirty = true
+		fmt.Fprintln(app.out, "Task no longer repeats.")
+		app.relist()
+		return nil
+	}
+	known := false
+	for _, recur := range recurrences {
+		known = known || recur == value
+	}
+	if !known {
+		weekdays, err := parseWeekdays(value)
+		if err != nil {
+			return err
+		}
+		value = weekdays
+	}
+	if task.Due == "" {
+		return errors.New("Set a due date first; repeats are counted from it.")
+	}
+	task.Recur = value
+	app.dirty = true
+	fmt.Fprintf(app.out, "Task repeats %s.\n", value)
+	app.relist()
+	return nil
+}
+
+// completeOccurrence handles completing a recurring task: the task stays
+// open and its due date moves to the next occurrence that is after today.
+// It reports false for a task with no due date left to repeat from, which
+// is then completed normally.
+func (app *TodoApp) completeOccurrence(task *Task) bool {
+	due, ok := storedDue(task.Due)
+	if !ok {
+		return false
+	}
+	due = nextOccurrence(due, task.Recur)
+	for !dayOf(due).After(today()) {
+		due = nextOccurrence(due, task.Recur)
+	}
+	task.Due = formatDueLike(task.Due, due)
+	fmt.Fprintf(app.out, "\"%s\" repeats; next due %s.\n", task.Description, app.formatDate(task.Due))
+	return true
+}
+
+// catchUpRecurring moves recurring tasks that fell due on an earlier day to
+// their next occurrence from today. In accumulate mode each missed
+// occurrence is also added as a one-off task.
+func (app *TodoApp) catchUpRecurring() {
+	accumulate := app.config.RecurCatchUp == "accumulate"
+	for i := 0; i < len(app.tasks); i++ {
+		task := &app.tasks[i]
+		due, ok := storedDue(task.Due)
+		if task.Recur == "" || !task.isOpen() || !ok || !dayOf(due).Before(today()) {
+			continue
+		}
+		var missed []Task
+		for dayOf(due).Before(today()) {
+			if accumulate {
+				occurrence := *task
+				occurrence.ID = app.nextID()
+				occurrence.Recur = ""
+				occurrence.Due = formatDueLike(task.Due, due)
+				occurrence.Tags = append([]string(nil), task.Tags...)
+				occurrence.Notes = append([]string(nil), task.Notes...)
+				missed = append(missed, occurrence)
+			}
+			due = nextOccurrence(due, task.Recur)
+		}
+		task.Due = formatDueLike(task.Due, due)
+		app.dirty = true
+		if len(missed) > 0 {
+			fmt.Fprintf(app.out, "\"%s\": added %d missed occurrence(s); next due %s.\n", task.Description, len(missed), app.formatDate(task.Due))
+			app.tasks = append(app.tasks[:i], append(missed, app.tasks[i:]...)...)
+			i += len(missed)
+		} else {
+			fmt.Fprintf(app.out, "\"%s\": skipped missed occurrences; next due %s.\n", task.Description, app.formatDate(task.Due))
+		}
+	}
+}
+
+func (app *TodoApp) setCatchUp(mode string) error {
+	switch strings.ToLower(mode) {
+	case "skip":
+		app.config.RecurCatchUp = ""
+		fmt.Fprintln(app.out, "Missed repeats will be skipped.")
+	case "accumulate":
+		app.config.RecurCatchUp = "accumulate"
+		fmt.Fprintln(app.out, "Missed repeats will be added as separate tasks.")
+	default:
+		return usageError("Unknown mode", mode, "catchup <skip|accumulate>")
+	}
+	app.saveConfig()
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// firstRun reports whether this looks like a new user: no config file and
+// no tasks file yet.
+func (app *TodoApp) firstRun() bool {
+	if _, err := os.Stat(configFileName()); !os.IsNotExist(err) {
+		return false
+	}
+	_, err := os.Stat(app.fileName)
+	return os.IsNotExist(err)
+}
+
+// ask prints question and returns the trimmed answer, or ok false at the
+// end of input.
+func (app *TodoApp) ask(question string) (string, bool) {
+	fmt.Fprint(app.out, question)
+	scanner := app.input()
+	if !scanner.Scan() {
+		fmt.Fprintln(app.out)
+		return "", false
+	}
+	return strings.TrimSpace(scanner.Text()), true
+}
+
+// runSetup asks a few questions on first run and writes the config. The
+// config is written even when setup is skipped, so it only runs once.
+func (app *TodoApp) runSetup() {
+	defer app.saveConfig()
+	fmt.Fprintln(app.out, "Welcome! A few questions to get set up. Press Enter for the default, or type \"skip\" to skip.")
+
+	for {
+		answer, ok := app.ask(fmt.Sprintf("Where should tasks be stored? [%s] ", app.fileName))
+		if !ok || answer == "skip" {
+			return
+		}
+		if answer == "" {
+			break
+		}
+		if home, err := os.UserHomeDir(); err == nil && strings.HasPrefix(answer, "~/") {
+			answer = filepath.Join(home, answer[2:])
+		}
+		fileName, err := filepath.Abs(answer)
+		if err == nil {
+			err = os.MkdirAll(filepath.Dir(fileName), 0755)
+		}
+		if err != nil {
+			fmt.Fprintln(app.out, "Can't use that location:", err)
+			continue
+		}
+		app.config.File = fileName
+		app.fileName = fileName
+		app.loadTasks()
+		app.loadJournal()
+		break
+	}
+
+	for {
+		answer, ok := app.ask("Use color? [Y/n] ")
+		if !ok || answer == "skip" {
+			return
+		}
+		switch strings.ToLower(answer) {
+		case "", "y", "yes":
+			app.config.Color = ""
+		case "n", "no":
+			app.config.Color = "never"
+		default:
+			continue
+		}
+		break
+	}
+
+	for {
+		answer, ok := app.ask("Show at startup: list, board or open tasks? [list] ")
+		if !ok || answer == "skip" {
+			return
+		}
+		switch view := strings.ToLower(answer); view {
+		case "", "list":
+			app.config.View = ""
+		case "board", "open":
+			app.config.View = view
+		default:
+			continue
+		}
+		break
+	}
+	fmt.Fprintf(app.out, "Settings saved to %s.\n", configFileName())
+}
+
+// showStartView lists the tasks the way the view setting asks for.
+func (app *TodoApp) showStartView() {
+	switch app.config.View {
+	case "board":
+		app.showBoard()
+	case "open":
+		app.listOpen(false)
+	default:
+		app.listTasks()
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import "fmt"
+
+// parentIndex returns the index of the task's parent, or -1 for a top-level
+// task or one whose parent has been removed.
+func (app *TodoApp) parentIndex(task Task) int {
+	if task.Parent == 0 {
+		return -1
+	}
+	return app.taskIndexByID(task.Parent)
+}
+
+// underParent reports whether the task at index sits right below its
+// parent, with only its siblings in between, so a listing can indent it.
+// Subtasks that sorting or moving has separated from their parent are
+// listed flush.
+func (app *TodoApp) underParent(index int) bool {
+	parent := app.tasks[index].Parent
+	if parent == 0 {
+		return false
+	}
+	for i := index - 1; i >= 0; i-- {
+		if app.tasks[i].ID == parent {
+			return true
+		}
+		if app.tasks[i].Parent != parent {
+			return false
+		}
+	}
+	return false
+}
+
+// subtaskProgress counts the completed and total subtasks of a task.
+// Cancelled subtasks are left out of both.
+func (app *TodoApp) subtaskProgress(task Task) (done, total int) {
+	if app.cache != nil {
+		counts := app.cache.progress[task.ID]
+		return counts[0], counts[1]
+	}
+	for _, t := range app.tasks {
+		if t.Parent != task.ID || t.Status == statusCancelled {
+			continue
+		}
+		total++
+		if t.IsCompleted {
+			done++
+		}
+	}
+	return done, total
+}
+
+// addSubtask inserts the new task after the parent and its existing
+// subtasks so it lists beneath them.
+func (app *TodoApp) addSubtask(index int, description string) error {
+	if index < 0 || index >= len(app.tasks) {
+		return app.invalidTaskNumber(index)
+	}
+	parent := app.tasks[index]
+	task := app.taskFromText(description)
+	task.Parent = parent.ID
+	position := index + 1
+	for position < len(app.tasks) && app.tasks[position].Parent == parent.ID {
+		position++
+	}
+	return app.insertTask(position, task)
+}
+
+// completeParent completes the parent of a just-completed subtask once none
+// of its subtasks are left open, when the autoParent preference is on.
+func (app *TodoApp) completeParent(task Task) {
+	if !app.config.AutoCompleteParent {
+		return
+	}
+	index := app.parentIndex(task)
+	if index < 0 || app.tasks[index].IsCompleted {
+		return
+	}
+	for _, t := range app.tasks {
+		if t.Parent == task.Parent && t.isOpen() {
+			return
+		}
+	}
+	fmt.Fprintf(app.out, "All subtasks done; completed \"%s\".\n", app.tasks[index].Description)
+	app.setCompleted(index, true)
+}
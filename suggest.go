@@ -0,0 +1,91 @@
+package main
+
+import "strings"
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// closestCommand finds the known command nearest to action. Short inputs
+// only match commands one edit away so that "z" doesn't suggest everything.
+func closestCommand(action string) (string, bool) {
+	maxDistance := 1
+	if len(action) > 4 {
+		maxDistance = 2
+	}
+	best, bestDistance := "", maxDistance+1
+	for _, name := range commandNames() {
+		if d := editDistance(action, name); d < bestDistance {
+			best, bestDistance = name, d
+		}
+	}
+	return best, best != ""
+}
+
+// fuzzyScore rates how well query matches text, lower being better. A
+// plain substring scores 0; otherwise the query's letters must appear in
+// order and the score grows with the letters skipped between them.
+func fuzzyScore(query, text string) (int, bool) {
+	query, text = foldText(query), foldText(text)
+	if strings.Contains(text, query) {
+		return 0, true
+	}
+	q, t := []rune(query), []rune(text)
+	start, gaps, matched := -1, 0, 0
+	for i := 0; i < len(t) && matched < len(q); i++ {
+		if t[i] == q[matched] {
+			if start < 0 {
+				start = i
+			}
+			matched++
+		} else if start >= 0 {
+			gaps++
+		}
+	}
+	if matched < len(q) {
+		return 0, false
+	}
+	return 1 + gaps, true
+}
+
+// foldReplacer maps accented Latin letters to their plain forms, so that
+// after lowercasing "Café" and "CAFE" compare equal.
+var foldReplacer = func() *strings.Replacer {
+	const accented = "àáâãäåçèéêëìíîïñòóôõöùúûüýÿāăąćĉċčďēĕėęěĝğġģĥĩīĭįĵķĺļľńņňōŏőŕŗřśŝşšţťũūŭůűųŵŷźżžđħıŀłøŧ"
+	const plain = "aaaaaaceeeeiiiinooooouuuuyyaaaccccdeeeeegggghiiiijklllnnnooorrrssssttuuuuuuwyzzzdhillot"
+	from, to := []rune(accented), []rune(plain)
+	pairs := []string{"æ", "ae", "œ", "oe", "ß", "ss"}
+	for i := range from {
+		pairs = append(pairs, string(from[i]), string(to[i]))
+	}
+	return strings.NewReplacer(pairs...)
+}()
+
+// foldText prepares text for loose comparison, ignoring case and accents.
+// Accents typed as separate combining marks, as in decomposed (NFD) text,
+// are dropped.
+func foldText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 0x300 && r <= 0x36f {
+			return -1
+		}
+		return r
+	}, foldReplacer.Replace(strings.ToLower(s)))
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFoldText(t *testing.T) {
+	tests := []struct{ a, b string }{
+		{"Café", "cafe"},
+		{"CAFÉ", "cafe"},
+		{"CAFE", "café"},
+		{"Straße", "STRASSE"},
+		{"Ærø", "aero"},
+		{"Łódź", "lodz"},
+		{"Cafe\u0301", "café"},
+		{"NAI\u0308VE", "naïve"},
+	}
+	for _, test := range tests {
+		if foldText(test.a) != foldText(test.b) {
+			t.Errorf("foldText(%q) = %q, foldText(%q) = %q; want equal", test.a, foldText(test.a), test.b, foldText(test.b))
+		}
+	}
+	if got := foldText("Straße"); got != "strasse" {
+		t.Errorf(`foldText("Straße") = %q, want "strasse"`, got)
+	}
+}
+
+func TestFuzzyScoreNonASCII(t *testing.T) {
+	tests := []struct {
+		query, text string
+		score       int
+		ok          bool
+	}{
+		{"cafe", "Buy coffee at the Café", 0, true},
+		{"CAFÉ", "café au lait", 0, true},
+		{"strasse", "Walk down Hauptstraße", 0, true},
+		{"cfé", "Café", 2, true},
+		{"über", "Call Uber", 0, true},
+		{"naïve", "naive plan", 0, true},
+		{"zoë", "Call Chloe", 0, false},
+	}
+	for _, test := range tests {
+		score, ok := fuzzyScore(test.query, test.text)
+		if score != test.score || ok != test.ok {
+			t.Errorf("fuzzyScore(%q, %q) = %d, %v; want %d, %v", test.query, test.text, score, ok, test.score, test.ok)
+		}
+	}
+}
+
+func TestCompactDropsAccentedDuplicates(t *testing.T) {
+	app, _ := newTestApp(t, "Café order", "cafe order", "CAFÉ ORDER ", "Straße", "strasse", "Tea")
+	if err := app.compactTasks("-f"); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, task := range app.tasks {
+		got = append(got, task.Description)
+	}
+	want := []string{"Café order", "Straße", "Tea"}
+	if len(got) != len(want) {
+		t.Fatalf("after compact: %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("after compact: %q, want %q", got, want)
+		}
+	}
+}
+
+func TestTagAndAssigneeMatchingFolds(t *testing.T) {
+	task := Task{Tags: []string{"café"}, Assignee: "José"}
+	for _, tag := range []string{"cafe", "CAFÉ", "cafe\u0301"} {
+		if !task.hasTag(tag) {
+			t.Errorf("hasTag(%q) = false for tag %q", tag, task.Tags[0])
+		}
+	}
+
+	app, out := newTestApp(t, "Order beans", "Fix printer")
+	app.tasks[0].Tags = []string{"café"}
+	app.tasks[0].Assignee = "José"
+	if err := app.filterTasks("#cafe @jose"); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); !strings.Contains(got, "Order beans") || strings.Contains(got, "Fix printer") {
+		t.Errorf("f #cafe @jose printed:\n%s", got)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Template is the reusable part of a task: what it says and how it is
+// filed, but not its dates or progress.
+type Template struct {
+	Description string   `json:"description"`
+	Priority    int      `json:"priority,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+func (app *TodoApp) templatesFileName() string {
+	ext := filepath.Ext(app.fileName)
+	return strings.TrimSuffix(app.fileName, ext) + ".templates.json"
+}
+
+func (app *TodoApp) loadTemplates() (map[string]Template, error) {
+	templates := make(map[string]Template)
+	data, err := ioutil.ReadFile(app.templatesFileName())
+	if os.IsNotExist(err) {
+		return templates, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading templates: %v", err)
+	}
+	if data, err = app.openData(app.templatesFileName(), data); err != nil {
+		return nil, fmt.Errorf("Error reading templates: %v", err)
+	}
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return nil, fmt.Errorf("Error parsing templates: %v", err)
+	}
+	return templates, nil
+}
+
+func (app *TodoApp) saveTemplates(templates map[string]Template) error {
+	data, err := json.MarshalIndent(templates, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Error encoding templates: %v", err)
+	}
+	if data, err = app.sealData(data); err != nil {
+		return fmt.Errorf("Error encrypting templates: %v", err)
+	}
+	if err := writeFileAtomic(app.templatesFileName(), data, 0644); err != nil {
+		return fmt.Errorf("Error writing templates: %v", err)
+	}
+	return nil
+}
+
+func (app *TodoApp) templateCommand(args string) error {
+	const usage = "tpl <save <task number> <name>|add <name>|list>"
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return usageError("", "", usage)
+	}
+	templates, err := app.loadTemplates()
+	if err != nil {
+		return err
+	}
+	switch fields[0] {
+	case "save":
+		index, name, err := app.taskNumberAndText(strings.TrimSpace(strings.TrimPrefix(args, "save")), "tpl save <task number> <name>")
+		if err != nil {
+			return err
+		}
+		if index < 0 || index >= len(app.tasks) {
+			return app.invalidTaskNumber(index)
+		}
+		task := app.tasks[index]
+		templates[name] = Template{Description: task.Description, Priority: task.Priority, Tags: append([]string(nil), task.Tags...)}
+		if err := app.saveTemplates(templates); err != nil {
+			return err
+		}
+		fmt.Fprintf(app.out, "Saved template %q.\n", name)
+	case "add":
+		name := strings.TrimSpace(strings.TrimPrefix(args, "add"))
+		if name == "" {
+			return usageError("", "", "tpl add <name>")
+		}
+		template, ok := templates[name]
+		if !ok {
+			return fmt.Errorf("No template named %q. Type \"tpl list\" to see them.", name)
+		}
+		task := app.newTask(template.Description)
+		task.Priority = template.Priority
+		task.Tags = append([]string(nil), template.Tags...)
+		return app.appendTask(task)
+	case "list":
+		if len(templates) == 0 {
+			fmt.Fprintln(app.out, "No templates yet. Save one with \"tpl save <task number> <name>\".")
+			return nil
+		}
+		names := make([]string, 0, len(templates))
+		for name := range templates {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			template := templates[name]
+			fmt.Fprintf(app.out, "  %s: %s%s\n", name, template.Description, app.taskDetails(Task{Priority: template.Priority, Tags: template.Tags}))
+		}
+	default:
+		return usageError("Unknown option", fields[0], usage)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// isTerminal reports whether file is a character device other than the
+// null device, which is one too but is what scripts redirect from.
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
+
+// useColor follows the NO_COLOR convention (https://no-color.org) and the
+// color setting, and never colors output that isn't going to a terminal.
+func (app *TodoApp) useColor() bool {
+	_, noColor := os.LookupEnv("NO_COLOR")
+	return !noColor && app.config.Color != "never" && app.outputIsTerminal()
+}
+
+// outputIsTerminal looks through the buffering a listing adds.
+func (app *TodoApp) outputIsTerminal() bool {
+	if app.cache != nil {
+		return app.cache.terminal
+	}
+	file, ok := app.out.(*os.File)
+	return ok && isTerminal(file)
+}
+
+// clearScreen clears the terminal and lists the tasks. Output that isn't a
+// terminal just gets the listing.
+func (app *TodoApp) clearScreen() {
+	if app.outputIsTerminal() {
+		fmt.Fprint(app.out, "\x1b[H\x1b[2J")
+	}
+	app.listTasks()
+}
+
+const (
+	colorGreen   = "32"
+	colorYellow  = "33"
+	colorReverse = "7"
+)
+
+func (app *TodoApp) colorize(color, s string) string {
+	if !app.useColor() {
+		return s
+	}
+	return "\x1b[" + color + "m" + s + "\x1b[0m"
+}
+
+// hyperlink wraps text in an OSC 8 escape so terminals that support it
+// make it clickable. Terminals without support show the text alone.
+func (app *TodoApp) hyperlink(target, text string) string {
+	if !app.outputIsTerminal() || os.Getenv("TERM") == "dumb" {
+		return text
+	}
+	return "\x1b]8;;" + target + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
+
+// linkify turns each http(s) URL in s into a hyperlink, leaving trailing
+// punctuation outside the link.
+func (app *TodoApp) linkify(s string) string {
+	if !strings.Contains(s, "://") {
+		return s
+	}
+	words := strings.Split(s, " ")
+	for i, word := range words {
+		if !strings.HasPrefix(word, "http://") && !strings.HasPrefix(word, "https://") {
+			continue
+		}
+		link := strings.TrimRight(word, ".,;:!?)")
+		words[i] = app.hyperlink(link, link) + word[len(link):]
+	}
+	return strings.Join(words, " ")
+}
+
+// terminalWidth uses $COLUMNS when the shell exports it and assumes 80
+// columns otherwise.
+func terminalWidth() int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	return 80
+}
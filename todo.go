@@ -3,245 +3,2390 @@ package main
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 )
 
 type Task struct {
-	Description string `json:"description"`
-	IsCompleted bool   `json:"isCompleted"`
+	ID          int       `json:"id"`
+	Description string    `json:"description"`
+	IsCompleted bool      `json:"isCompleted"`
+	StartDate   string    `json:"startDate,omitempty"`
+	Notes       []string  `json:"notes,omitempty"`
+	CreatedAt   time.Time `json:"createdAt,omitzero"`
+	PlanDate    string    `json:"planDate,omitempty"`
+	Due         string    `json:"due,omitempty"`
+	Priority    int       `json:"priority,omitempty"`
+	CompletedAt time.Time `json:"completedAt,omitzero"`
+	Tags        []string  `json:"tags,omitempty"`
+	Source      string    `json:"source,omitempty"`
+	Recur       string    `json:"recur,omitempty"`
+	Status      string    `json:"status,omitempty"`
+	Someday     bool      `json:"someday,omitempty"`
+	Link        string    `json:"link,omitempty"`
+	Parent      int       `json:"parent,omitempty"`
+	Estimate    int       `json:"estimate,omitempty"`
+	BlockedBy   []int     `json:"blockedBy,omitempty"`
+	Assignee    string    `json:"assignee,omitempty"`
+}
+
+// statusCancelled marks a task that was abandoned rather than done. Such a
+// task has IsCompleted false, so files stay readable by older versions.
+const statusCancelled = "cancelled"
+
+// statusDoing marks an open task that is in progress.
+const statusDoing = "doing"
+
+// isOpen reports whether the task still needs doing.
+func (task Task) isOpen() bool {
+	return !task.IsCompleted && task.Status != statusCancelled
+}
+
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+}
+
+// hasTag compares tags loosely, so #café and #cafe are the same tag.
+func (task Task) hasTag(tag string) bool {
+	tag = foldText(tag)
+	for _, t := range task.Tags {
+		if foldText(t) == tag {
+			return true
+		}
+	}
+	return false
+}
+
+var priorityNames = []string{"none", "low", "medium", "high"}
+
+// parsePriority accepts a priority name, its first letter, or 0-3.
+func parsePriority(s string) (int, bool) {
+	s = strings.ToLower(s)
+	for priority, name := range priorityNames {
+		if s == name || s == name[:1] || s == strconv.Itoa(priority) {
+			return priority, true
+		}
+	}
+	return 0, false
+}
+
+// inlineMetadata pulls priority and tags out of a new task's description.
+// Priority is a word such as !high or !h anywhere, or a trailing !, !! or
+// !!! for low, medium or high. Tags are words starting with #, except
+// issue-style numbers like #42. Other ! and # characters are left alone.
+func inlineMetadata(description string) (string, int, []string) {
+	words := strings.Fields(description)
+	priority := 0
+	var tags []string
+	kept := make([]string, 0, len(words))
+	for i, word := range words {
+		if tag := normalizeTag(word); strings.HasPrefix(word, "#") && strings.Trim(tag, "0123456789#") != "" {
+			tags = append(tags, tag)
+			continue
+		}
+		if name := strings.TrimPrefix(word, "!"); name != word && name != "" && !strings.ContainsAny(name[:1], "0123456789") {
+			if p, ok := parsePriority(name); ok {
+				priority = p
+				continue
+			}
+		}
+		if i == len(words)-1 && len(word) <= 3 && strings.Trim(word, "!") == "" {
+			priority = len(word)
+			continue
+		}
+		kept = append(kept, word)
+	}
+	if len(kept) == len(words) || len(kept) == 0 {
+		return description, 0, nil
+	}
+	return strings.Join(kept, " "), priority, tags
+}
+
+func (task Task) isDeferred(today time.Time) bool {
+	start, ok := storedDate(task.StartDate)
+	return ok && start.After(today)
+}
+
+// isHidden reports whether the main listing leaves the task out.
+func (task Task) isHidden(today time.Time) bool {
+	return task.Someday || task.isDeferred(today)
 }
 
 type TodoApp struct {
-	tasks    []Task
-	fileName string
+	tasks       []Task
+	fileName    string
+	dirty       bool
+	config      Config
+	fileConfig  Config
+	envKeys     map[string]bool
+	events      []Event
+	eventsDirty bool
+	quiet       bool
+	journal     []JournalEntry
+	skipJournal bool
+	mu          sync.Mutex
+	in          io.Reader
+	scanner     *bufio.Scanner
+	out         io.Writer
+	errOut      io.Writer
+	notLoaded   string
+	lastID      int
+	savedLastID int
+	saved       []Task
+	savedLines  int
+	cache       *listCache
+	passphrase  string
+	key, salt   []byte
+}
+
+func NewTodoApp(fileName string) *TodoApp {
+	app := &TodoApp{
+		fileName:   fileName,
+		in:         os.Stdin,
+		passphrase: os.Getenv(passphraseEnv),
+		out:        os.Stdout,
+		errOut:     os.Stdout,
+	}
+	app.loadConfig()
+	app.applyEnv()
+	if app.fileName == "" {
+		app.fileName = "tasks.json"
+		if app.config.File != "" {
+			app.fileName = app.config.File
+		}
+	}
+	app.loadTasks()
+	app.loadJournal()
+	if app.config.Streaks {
+		app.loadEvents()
+	}
+	return app
+}
+
+// defaultMaxFileMB caps the tasks file size so pointing at the wrong file
+// can't exhaust memory. The maxFileMB config setting overrides it.
+const defaultMaxFileMB = 10
+
+func (app *TodoApp) maxFileSize() int64 {
+	if app.config.MaxFileMB > 0 {
+		return int64(app.config.MaxFileMB) << 20
+	}
+	return defaultMaxFileMB << 20
+}
+
+func (app *TodoApp) loadTasks() {
+	app.notLoaded = ""
+	app.loadLastID()
+	if info, err := os.Stat(app.fileName); err == nil && info.Size() > app.maxFileSize() {
+		app.notLoaded = "it is over the size limit"
+		fmt.Fprintf(app.out, "Error reading file: %s is %.1f MB, over the %d MB limit. Starting with an empty list and leaving the file alone.\n", app.fileName, float64(info.Size())/(1<<20), app.maxFileSize()>>20)
+		return
+	}
+	data, err := ioutil.ReadFile(app.fileName)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Fprintln(app.out, "Error reading file:", err)
+		}
+		return
+	}
+	if data, err = app.openData(app.fileName, data); err != nil {
+		app.notLoaded = err.Error()
+		fmt.Fprintf(app.out, "Error reading file: %v. Starting with an empty list and leaving the file alone.\n", err)
+		return
+	}
+	tasks, lines, torn, err := decodeTasks(data)
+	if err != nil {
+		app.notLoaded = "it couldn't be parsed"
+		fmt.Fprintf(app.out, "Error parsing JSON: %v. Starting with an empty list and leaving the file alone.\n", err)
+		return
+	}
+	app.tasks = tasks
+	app.rememberSaved(!isArray(data) && !torn, lines)
+	if torn {
+		fmt.Fprintf(app.out, "Ignoring an incomplete last line in %s; it will be rewritten on the next save.\n", app.fileName)
+	}
+	for i := range app.tasks {
+		if app.tasks[i].ID == 0 {
+			app.tasks[i].ID = app.nextID()
+		}
+	}
+	app.clearStalePlans()
+	app.catchUpRecurring()
+}
+
+// switchFile saves the current list and makes fileName the active one. A
+// file that doesn't exist yet starts as an empty list.
+func (app *TodoApp) switchFile(fileName string) error {
+	if fileName == "" {
+		return usageError("", "", "file <path>")
+	}
+	if err := app.flush(); err != nil {
+		return err
+	}
+	app.fileName = fileName
+	app.tasks = nil
+	app.loadTasks()
+	app.loadJournal()
+	if app.config.Streaks {
+		app.loadEvents()
+	}
+	// The switch itself isn't a change to either list, so don't journal it.
+	app.skipJournal = true
+	if _, err := os.Stat(fileName); os.IsNotExist(err) {
+		fmt.Fprintf(app.out, "Switched to %s (new, empty list).\n", fileName)
+	} else {
+		fmt.Fprintf(app.out, "Switched to %s.\n", fileName)
+	}
+	app.relist()
+	return nil
+}
+
+// clearStalePlans drops plan flags left over from a previous day.
+func (app *TodoApp) clearStalePlans() {
+	now := today().Format(dateLayout)
+	for i := range app.tasks {
+		if app.tasks[i].PlanDate != "" && app.tasks[i].PlanDate != now {
+			app.tasks[i].PlanDate = ""
+			app.dirty = true
+		}
+	}
+}
+
+// assignIDs gives tasks saved before IDs existed a stable ID. It is
+// deterministic so the same file always yields the same IDs.
+func assignIDs(tasks []Task) {
+	maxID := 0
+	for _, task := range tasks {
+		if task.ID > maxID {
+			maxID = task.ID
+		}
+	}
+	for i := range tasks {
+		if tasks[i].ID == 0 {
+			maxID++
+			tasks[i].ID = maxID
+		}
+	}
+}
+
+// nextID hands out a new task ID. IDs are never reused, even after the
+// newest task is deleted, so anything keyed on one can't pick up a
+// different task.
+func (app *TodoApp) nextID() int {
+	for _, task := range app.tasks {
+		app.lastID = max(app.lastID, task.ID)
+	}
+	app.lastID++
+	return app.lastID
+}
+
+func (app *TodoApp) lastIDFileName() string {
+	ext := filepath.Ext(app.fileName)
+	return strings.TrimSuffix(app.fileName, ext) + ".lastid"
+}
+
+// loadLastID reads the highest ID handed out so far. Lists saved before it
+// was recorded fall back to the highest ID in the archive.
+func (app *TodoApp) loadLastID() {
+	app.lastID, app.savedLastID = 0, 0
+	data, err := ioutil.ReadFile(app.lastIDFileName())
+	if err == nil {
+		app.lastID, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+		app.savedLastID = app.lastID
+		return
+	}
+	if archived, err := app.loadArchive(); err == nil {
+		for _, task := range archived {
+			app.lastID = max(app.lastID, task.ID)
+		}
+	}
+}
+
+func (app *TodoApp) saveLastID() error {
+	if app.lastID <= app.savedLastID {
+		return nil
+	}
+	if err := writeFileAtomic(app.lastIDFileName(), []byte(strconv.Itoa(app.lastID)+"\n"), 0644); err != nil {
+		return err
+	}
+	app.savedLastID = app.lastID
+	return nil
+}
+
+// encodeTasks uses the configured layout. decodeTasks reads any of them.
+func (app *TodoApp) encodeTasks() ([]byte, error) {
+	if app.config.JSONLines {
+		return encodeLines(app.tasks)
+	}
+	if app.config.CompactJSON {
+		return json.Marshal(app.tasks)
+	}
+	indent := app.config.JSONIndent
+	if indent == 0 {
+		indent = 2
+	}
+	return json.MarshalIndent(app.tasks, "", strings.Repeat(" ", indent))
+}
+
+func (app *TodoApp) saveTasks() error {
+	if app.notLoaded != "" {
+		return fmt.Errorf("Not saving: %s wasn't loaded because %s.", app.fileName, app.notLoaded)
+	}
+	if err := app.saveLastID(); err != nil {
+		return fmt.Errorf("Error writing file: %v", err)
+	}
+	if appended, err := app.appendChanges(); appended || err != nil {
+		if err != nil {
+			return fmt.Errorf("Error writing file: %v", err)
+		}
+		app.rememberSaved(true, app.savedLines)
+		app.dirty = false
+		return nil
+	}
+	data, err := app.encodeTasks()
+	if err != nil {
+		return fmt.Errorf("Error encoding JSON: %v", err)
+	}
+	if data, err = app.sealData(data); err != nil {
+		return fmt.Errorf("Error encrypting file: %v", err)
+	}
+	if app.passphrase != "" {
+		if err := app.removePlainCopies(); err != nil {
+			return fmt.Errorf("Error removing unencrypted copies: %v", err)
+		}
+	}
+	if err := app.rotateBackups(); err != nil {
+		return fmt.Errorf("Error backing up file: %v", err)
+	}
+	err = writeFileAtomic(app.fileName, data, 0644)
+	if err != nil {
+		return fmt.Errorf("Error writing file: %v", err)
+	}
+	app.rememberSaved(app.config.JSONLines, len(app.tasks))
+	app.dirty = false
+	return nil
+}
+
+func (app *TodoApp) flush() error {
+	if app.eventsDirty {
+		app.saveEvents()
+	}
+	if app.dirty {
+		return app.saveTasks()
+	}
+	return nil
+}
+
+// writeFileAtomic writes to a temporary file in the same directory and
+// renames it over the target, so a crash never leaves a truncated file.
+func writeFileAtomic(fileName string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(fileName), filepath.Base(fileName)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), fileName)
+}
+
+func (app *TodoApp) newTask(description string) Task {
+	return Task{ID: app.nextID(), Description: description, CreatedAt: time.Now()}
+}
+
+// addTask adds a task typed by the user, reading inline priority and tags.
+func (app *TodoApp) addTask(description string) error {
+	return app.appendTask(app.taskFromText(description))
+}
+
+// taskFromText creates a task, taking priority and tags from the text.
+func (app *TodoApp) taskFromText(description string) Task {
+	description, priority, tags := inlineMetadata(description)
+	task := app.newTask(description)
+	task.Priority = priority
+	for _, tag := range tags {
+		if !task.hasTag(tag) {
+			task.Tags = append(task.Tags, tag)
+		}
+	}
+	return task
+}
+
+// insertAt adds a task at index, where len(app.tasks) appends.
+func (app *TodoApp) insertAt(index int, description string) error {
+	if index < 0 || index > len(app.tasks) {
+		return fmt.Errorf("Invalid position %d: choose 1-%d.", index+1, len(app.tasks)+1)
+	}
+	return app.insertTask(index, app.taskFromText(description))
+}
+
+// appendTask adds task to the end of the list, enforcing the open-task
+// limit.
+func (app *TodoApp) appendTask(task Task) error {
+	return app.insertTask(len(app.tasks), task)
+}
+
+func (app *TodoApp) insertTask(position int, task Task) error {
+	if err := app.checkOpenLimit(task); err != nil {
+		return err
+	}
+	app.tasks = append(app.tasks[:position], append([]Task{task}, app.tasks[position:]...)...)
+	app.dirty = true
+	app.relist()
+	app.reportOpenLimit()
+	return nil
+}
+
+// checkOpenLimit refuses to add an open task once the limit is reached in
+// strict mode.
+func (app *TodoApp) checkOpenLimit(task Task) error {
+	limit := app.config.OpenLimit
+	if limit <= 0 || !app.config.StrictLimit || !task.isOpen() {
+		return nil
+	}
+	if open := app.openCount(); open >= limit {
+		return fmt.Errorf("Open-task limit reached (%d of %d). Finish something first or raise the limit.", open, limit)
+	}
+	return nil
+}
+
+// reportOpenLimit warns about the open-task count after adding, once it is
+// near or over the limit.
+func (app *TodoApp) reportOpenLimit() {
+	limit := app.config.OpenLimit
+	if limit <= 0 {
+		return
+	}
+	if open := app.openCount(); open > limit {
+		fmt.Fprintf(app.out, "Warning: %d open tasks, over your limit of %d.\n", open, limit)
+	} else if open*5 >= limit*4 {
+		fmt.Fprintf(app.out, "%d of %d open tasks.\n", open, limit)
+	}
+}
+
+func (app *TodoApp) setOpenLimit(args string) error {
+	fields := strings.Fields(args)
+	usage := "limit <count|off> [strict]"
+	if len(fields) > 2 {
+		return usageError("Too many arguments", args, usage)
+	}
+	if len(fields) == 0 {
+		return usageError("", "", usage)
+	}
+	if strings.ToLower(fields[0]) == "off" {
+		app.config.OpenLimit = 0
+		app.config.StrictLimit = false
+		app.saveConfig()
+		fmt.Fprintln(app.out, "Open-task limit off.")
+		return nil
+	}
+	limit, err := strconv.Atoi(fields[0])
+	if err != nil || limit < 1 {
+		return usageError("Expected a positive number or \"off\"", fields[0], usage)
+	}
+	strict := len(fields) == 2
+	if strict && strings.ToLower(fields[1]) != "strict" {
+		return usageError("Unknown option", fields[1], usage)
+	}
+	app.config.OpenLimit = limit
+	app.config.StrictLimit = strict
+	app.saveConfig()
+	if strict {
+		fmt.Fprintf(app.out, "Adding is refused at %d open tasks (%d open now).\n", limit, app.openCount())
+	} else {
+		fmt.Fprintf(app.out, "Warning above %d open tasks (%d open now).\n", limit, app.openCount())
+	}
+	return nil
+}
+
+// capture adds each line typed as a new task until a blank line. The
+// tasks are saved together once the command finishes.
+func (app *TodoApp) capture() error {
+	fmt.Fprintln(app.out, "Capturing tasks, one per line. Finish with a blank line.")
+	descriptions := app.readLines("  + ")
+	captured := 0
+	var err error
+	for _, description := range descriptions {
+		task := app.taskFromText(description)
+		if err = app.checkOpenLimit(task); err != nil {
+			break
+		}
+		app.tasks = append(app.tasks, task)
+		captured++
+	}
+	if captured > 0 {
+		app.dirty = true
+	}
+	if captured < len(descriptions) {
+		fmt.Fprintf(app.out, "Captured %d of %d task(s).\n", captured, len(descriptions))
+	} else {
+		fmt.Fprintf(app.out, "Captured %d task(s).\n", captured)
+	}
+	app.relist()
+	app.reportOpenLimit()
+	return err
+}
+
+// listCache holds lookups that per-task details would otherwise redo for
+// every line, which made long listings quadratic. It lives for one listing.
+type listCache struct {
+	indexByID map[int]int
+	progress  map[int][2]int
+	numbers   []int
+	terminal  bool
+}
+
+// beginListing buffers output and caches lookups until the returned
+// function is called.
+func (app *TodoApp) beginListing() func() {
+	cache := &listCache{
+		indexByID: make(map[int]int, len(app.tasks)),
+		progress:  make(map[int][2]int),
+		terminal:  app.outputIsTerminal(),
+	}
+	for i, task := range app.tasks {
+		if _, ok := cache.indexByID[task.ID]; !ok {
+			cache.indexByID[task.ID] = i
+		}
+		if task.Parent != 0 && task.Status != statusCancelled {
+			counts := cache.progress[task.Parent]
+			counts[1]++
+			if task.IsCompleted {
+				counts[0]++
+			}
+			cache.progress[task.Parent] = counts
+		}
+	}
+	if app.config.RelativeNumbers {
+		cache.numbers = make([]int, len(app.tasks))
+		for n, index := range app.numberOrder() {
+			cache.numbers[index] = n + 1
+		}
+	}
+	out := app.out
+	buffered := bufio.NewWriter(out)
+	app.out = buffered
+	app.cache = cache
+	return func() {
+		buffered.Flush()
+		app.out = out
+		app.cache = nil
+	}
+}
+
+func (app *TodoApp) listTasks() {
+	if len(app.tasks) == 0 {
+		fmt.Fprintln(app.out, "No tasks.")
+		return
+	}
+	done := app.beginListing()
+	defer done()
+	app.writeListing()
+}
+
+// writeListing prints the main listing. Without beginListing it computes
+// every lookup afresh and writes straight to app.out.
+func (app *TodoApp) writeListing() {
+	now := today()
+	deferred, someday := 0, 0
+	fmt.Fprintln(app.out)
+	app.printRecentlyDone()
+	for i, task := range app.tasks {
+		if task.Someday {
+			someday++
+			continue
+		}
+		if task.isDeferred(now) {
+			deferred++
+			continue
+		}
+		app.printTask(i, task)
+	}
+	if deferred > 0 {
+		fmt.Fprintf(app.out, "(%d deferred)\n", deferred)
+	}
+	if someday > 0 {
+		fmt.Fprintf(app.out, "(%d someday)\n", someday)
+	}
+	if app.config.RelativeNumbers {
+		fmt.Fprintln(app.out, "(relative numbers: type \"renumber\" for positions)")
+	} else if deferred+someday > 0 {
+		fmt.Fprintln(app.out, "(numbers are positions: type \"renumber\" to count only listed tasks)")
+	}
+	fmt.Fprintln(app.out)
+}
+
+// printRecentlyDone shows the last few completions above the list when the
+// recentDone preference is set. Rows are unnumbered so the numbers below
+// stay the ones commands use.
+func (app *TodoApp) printRecentlyDone() {
+	if app.config.RecentDone <= 0 {
+		return
+	}
+	var done []Task
+	for _, task := range app.tasks {
+		if task.IsCompleted && !task.CompletedAt.IsZero() {
+			done = append(done, task)
+		}
+	}
+	if len(done) == 0 {
+		return
+	}
+	sort.SliceStable(done, func(i, j int) bool {
+		return done[i].CompletedAt.After(done[j].CompletedAt)
+	})
+	if len(done) > app.config.RecentDone {
+		done = done[:app.config.RecentDone]
+	}
+	fmt.Fprintln(app.out, "Recently done:")
+	for _, task := range done {
+		fmt.Fprintf(app.out, "   %s %s (%s)\n", statusMark(task), task.Description, app.formatDate(task.CompletedAt.Local().Format(dateTimeLayout)))
+	}
+	fmt.Fprintln(app.out)
+}
+
+func (app *TodoApp) setRecentDone(arg string) error {
+	if strings.ToLower(arg) == "off" {
+		app.config.RecentDone = 0
+		app.saveConfig()
+		fmt.Fprintln(app.out, "Recently done section off.")
+		return nil
+	}
+	count, err := strconv.Atoi(arg)
+	if err != nil || count < 1 {
+		return usageError("Expected a positive number or \"off\"", arg, "recent <count|off>")
+	}
+	app.config.RecentDone = count
+	app.saveConfig()
+	fmt.Fprintf(app.out, "Showing the %d most recently completed tasks above the list.\n", count)
+	return nil
+}
+
+// strikethrough uses combining characters so the effect survives pasting
+// into plain-text email.
+func strikethrough(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		b.WriteRune(r)
+		b.WriteRune('\u0336')
+	}
+	return b.String()
+}
+
+func (app *TodoApp) printPlain(strike bool) {
+	for n, i := range app.plainOrder() {
+		description := app.tasks[i].Description
+		if strike && !app.tasks[i].isOpen() {
+			description = strikethrough(description)
+		}
+		fmt.Fprintf(app.out, "%d. %s\n", n+1, description)
+	}
+}
+
+// plainOrder lists every task in number order, which is its position
+// unless relative numbering is on.
+func (app *TodoApp) plainOrder() []int {
+	if app.config.RelativeNumbers {
+		return app.numberOrder()
+	}
+	order := make([]int, len(app.tasks))
+	for i := range order {
+		order[i] = i
+	}
+	return order
+}
+
+func (app *TodoApp) printTask(index int, task Task) {
+	if app.underParent(index) {
+		fmt.Fprint(app.out, "  ")
+	}
+	if app.config.HideNumbers {
+		fmt.Fprintf(app.out, "%s %s%s\n", statusMark(task), app.linkify(task.Description), app.taskDetails(task))
+		return
+	}
+	fmt.Fprintf(app.out, "%d. %s %s%s\n", app.number(index), statusMark(task), app.linkify(task.Description), app.taskDetails(task))
+}
+
+// taskDetails is the metadata shown after a task's description in listings.
+func (app *TodoApp) taskDetails(task Task) string {
+	details := ""
+	if done, total := app.subtaskProgress(task); total > 0 {
+		details += fmt.Sprintf(" [%d/%d]", done, total)
+	}
+	if task.Priority > 0 {
+		details += " [" + priorityNames[task.Priority] + "]"
+	}
+	if task.Due != "" {
+		details += " (due " + app.formatDate(task.Due) + ")"
+	}
+	if task.Recur != "" {
+		details += " (" + task.Recur + ")"
+	}
+	if task.Estimate > 0 {
+		details += " ~" + formatEstimate(task.Estimate)
+	}
+	if task.isOpen() && len(app.openBlockers(task)) > 0 {
+		details += " (blocked)"
+	}
+	if task.Assignee != "" {
+		details += " @" + task.Assignee
+	}
+	for _, tag := range task.Tags {
+		details += " #" + tag
+	}
+	if task.Link != "" {
+		details += " " + app.hyperlink(task.Link, task.Link)
+	}
+	return details
+}
+
+func (app *TodoApp) listAddedToday() {
+	now := today()
+	found := false
+	for i, task := range app.tasks {
+		if !task.CreatedAt.IsZero() && dayOf(task.CreatedAt.Local()).Equal(now) {
+			if !found {
+				fmt.Fprintln(app.out)
+			}
+			found = true
+			app.printTask(i, task)
+		}
+	}
+	if found {
+		fmt.Fprintln(app.out)
+	} else {
+		fmt.Fprintln(app.out, "No tasks added today.")
+	}
+}
+
+func (app *TodoApp) togglePlan(index int) error {
+	if index < 0 || index >= len(app.tasks) {
+		return app.invalidTaskNumber(index)
+	}
+	task := &app.tasks[index]
+	if task.PlanDate == today().Format(dateLayout) {
+		task.PlanDate = ""
+		fmt.Fprintln(app.out, "Removed from today's plan.")
+	} else {
+		task.PlanDate = today().Format(dateLayout)
+		fmt.Fprintln(app.out, "Added to today's plan.")
+	}
+	app.dirty = true
+	app.showPlan()
+	return nil
+}
+
+func (app *TodoApp) showPlan() {
+	now := today().Format(dateLayout)
+	found := false
+	for i, task := range app.tasks {
+		if task.PlanDate == now {
+			if !found {
+				fmt.Fprintln(app.out)
+				fmt.Fprintln(app.out, "Today's plan:")
+			}
+			found = true
+			app.printTask(i, task)
+		}
+	}
+	if found {
+		fmt.Fprintln(app.out)
+	} else {
+		fmt.Fprintln(app.out, "Nothing planned for today.")
+	}
+}
+
+// daysBetween counts calendar days, so it isn't thrown off by DST.
+func daysBetween(from, to time.Time) int {
+	a := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	b := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(b.Sub(a).Hours() / 24)
+}
+
+// listOpen prints the tasks still to do, leaving out deferred and someday
+// ones. In quiet mode an empty list prints nothing, so cron mails nothing.
+func (app *TodoApp) listOpen(asJSON bool) error {
+	now := today()
+	open := []Task{}
+	var indices []int
+	for i, task := range app.tasks {
+		if task.isOpen() && !task.isHidden(now) {
+			open = append(open, task)
+			indices = append(indices, i)
+		}
+	}
+	if asJSON {
+		data, err := json.MarshalIndent(open, "", "  ")
+		if err != nil {
+			return fmt.Errorf("Error encoding JSON: %v", err)
+		}
+		fmt.Fprintln(app.out, string(data))
+		return nil
+	}
+	if len(indices) == 0 {
+		if !app.quiet {
+			fmt.Fprintln(app.out, "No open tasks.")
+		}
+		return nil
+	}
+	for _, i := range indices {
+		app.printTask(i, app.tasks[i])
+	}
+	return nil
+}
+
+// exportTasks writes the tasks the main listing shows to a JSON file, or
+// every task with -all.
+func (app *TodoApp) exportTasks(args string) error {
+	const usage = "export [-all] <file>"
+	fields := strings.Fields(args)
+	all := len(fields) > 0 && fields[0] == "-all"
+	if all {
+		fields = fields[1:]
+	}
+	if len(fields) != 1 {
+		return usageError("Expected one file name", args, usage)
+	}
+	now := today()
+	exported := []Task{}
+	for _, task := range app.tasks {
+		if all || !task.isHidden(now) {
+			exported = append(exported, task)
+		}
+	}
+	data, err := json.MarshalIndent(exported, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Error encoding JSON: %v", err)
+	}
+	if err := writeFileAtomic(fields[0], data, 0644); err != nil {
+		return fmt.Errorf("Error writing file: %v", err)
+	}
+	if len(exported) < len(app.tasks) {
+		fmt.Fprintf(app.out, "Exported %d of %d tasks to %s; -all includes the hidden ones.\n", len(exported), len(app.tasks), fields[0])
+	} else {
+		fmt.Fprintf(app.out, "Exported %d tasks to %s.\n", len(exported), fields[0])
+	}
+	return nil
+}
+
+// findRegexp lists the tasks whose description matches pattern, with their
+// usual numbers. Use (?i) in the pattern to ignore case.
+func (app *TodoApp) findRegexp(pattern string) error {
+	if pattern == "" {
+		return usageError("", "", "fx <regex>")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("Invalid pattern: %v", err)
+	}
+	found := false
+	for i, task := range app.tasks {
+		if re.MatchString(task.Description) {
+			if !found {
+				fmt.Fprintln(app.out)
+			}
+			found = true
+			app.printTask(i, task)
+		}
+	}
+	if found {
+		fmt.Fprintln(app.out)
+	} else {
+		fmt.Fprintln(app.out, "No tasks match.")
+	}
+	return nil
+}
+
+// filterTasks lists tasks carrying every tag and assignee given, or any of
+// them with -or, keeping their list numbers.
+func (app *TodoApp) filterTasks(args string) error {
+	const usage = "f <#tag|@name> ... [-or]"
+	matchAny := false
+	var tags, names []string
+	for _, word := range strings.Fields(args) {
+		switch {
+		case word == "-or":
+			matchAny = true
+		case strings.HasPrefix(word, "#") && normalizeTag(word) != "":
+			tags = append(tags, normalizeTag(word))
+		case strings.HasPrefix(word, "@") && len(word) > 1:
+			names = append(names, word[1:])
+		default:
+			return usageError("Expected a #tag or @name", word, usage)
+		}
+	}
+	wanted := len(tags) + len(names)
+	if wanted == 0 {
+		return usageError("", "", usage)
+	}
+	matched := 0
+	for i, task := range app.tasks {
+		hits := 0
+		for _, tag := range tags {
+			if task.hasTag(tag) {
+				hits++
+			}
+		}
+		for _, name := range names {
+			if foldText(task.Assignee) == foldText(name) {
+				hits++
+			}
+		}
+		if hits == wanted || matchAny && hits > 0 {
+			if matched == 0 {
+				fmt.Fprintln(app.out)
+			}
+			matched++
+			app.printTask(i, task)
+		}
+	}
+	if matched == 0 {
+		fmt.Fprintln(app.out, "No tasks match.")
+		return nil
+	}
+	fmt.Fprintln(app.out)
+	fmt.Fprintf(app.out, "%d task(s) match.\n", matched)
+	return nil
+}
+
+// listUnassigned lists open tasks nobody is assigned to, or finished ones
+// too with -all.
+func (app *TodoApp) listUnassigned(args string) error {
+	if args != "" && args != "-all" {
+		return usageError("Unknown option", args, "unassigned [-all]")
+	}
+	count := 0
+	for i, task := range app.tasks {
+		if task.Assignee != "" || args == "" && !task.isOpen() {
+			continue
+		}
+		if count == 0 {
+			fmt.Fprintln(app.out)
+		}
+		count++
+		app.printTask(i, task)
+	}
+	if count == 0 {
+		fmt.Fprintln(app.out, "No unassigned tasks.")
+		return nil
+	}
+	fmt.Fprintln(app.out)
+	fmt.Fprintf(app.out, "%d task(s) unassigned.\n", count)
+	return nil
+}
+
+// countWords totals tasks, words and characters in descriptions, and in
+// notes too with -notes, split by whether tasks are open.
+func (app *TodoApp) countWords(args string) error {
+	if args != "" && args != "-notes" {
+		return usageError("Unknown option", args, "wc [-notes]")
+	}
+	type counts struct{ tasks, words, chars int }
+	var open, completed, cancelled, total counts
+	for _, task := range app.tasks {
+		texts := []string{task.Description}
+		if args == "-notes" {
+			texts = append(texts, task.Notes...)
+		}
+		group := &open
+		if task.IsCompleted {
+			group = &completed
+		} else if task.Status == statusCancelled {
+			group = &cancelled
+		}
+		for _, c := range []*counts{group, &total} {
+			c.tasks++
+			for _, text := range texts {
+				c.words += len(strings.Fields(text))
+				c.chars += utf8.RuneCountInString(text)
+			}
+		}
+	}
+	fmt.Fprintf(app.out, "%-10s %7s %7s %7s\n", "", "Tasks", "Words", "Chars")
+	rows := []struct {
+		name string
+		counts
+	}{{"Open", open}, {"Completed", completed}, {"Cancelled", cancelled}, {"Total", total}}
+	for _, row := range rows {
+		if row.name == "Cancelled" && row.tasks == 0 {
+			continue
+		}
+		fmt.Fprintf(app.out, "%-10s %7d %7d %7d\n", row.name, row.tasks, row.words, row.chars)
+	}
+	return nil
+}
+
+// listUpcoming lists open tasks due from today through the next days days,
+// soonest first. Overdue tasks are left to listOverdue.
+func (app *TodoApp) listUpcoming(arg string) error {
+	days := 3
+	if arg != "" {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 {
+			return usageError("Expected a number of days", arg, "upcoming [days]")
+		}
+		days = n
+	}
+	now := today()
+	last := now.AddDate(0, 0, days)
+	var upcoming []int
+	for i, task := range app.tasks {
+		due, ok := storedDue(task.Due)
+		if ok && task.isOpen() && !dayOf(due).Before(now) && !dayOf(due).After(last) {
+			upcoming = append(upcoming, i)
+		}
+	}
+	if len(upcoming) == 0 {
+		fmt.Fprintf(app.out, "Nothing due in the next %d days.\n", days)
+		return nil
+	}
+	sort.SliceStable(upcoming, func(i, j int) bool {
+		a, _ := storedDue(app.tasks[upcoming[i]].Due)
+		b, _ := storedDue(app.tasks[upcoming[j]].Due)
+		return a.Before(b)
+	})
+	fmt.Fprintln(app.out)
+	for _, i := range upcoming {
+		task := app.tasks[i]
+		fmt.Fprintf(app.out, "%d. %s %s (due %s)\n", app.number(i), statusMark(task), task.Description, app.formatDate(task.Due))
+	}
+	fmt.Fprintln(app.out)
+	return nil
+}
+
+func (app *TodoApp) listOverdue() {
+	now := today()
+	var overdue []int
+	for i, task := range app.tasks {
+		due, ok := storedDue(task.Due)
+		if ok && task.isOpen() && dayOf(due).Before(now) {
+			overdue = append(overdue, i)
+		}
+	}
+	if len(overdue) == 0 {
+		fmt.Fprintln(app.out, "Nothing overdue!")
+		return
+	}
+	sort.SliceStable(overdue, func(i, j int) bool {
+		a, _ := storedDue(app.tasks[overdue[i]].Due)
+		b, _ := storedDue(app.tasks[overdue[j]].Due)
+		return a.Before(b)
+	})
+	fmt.Fprintln(app.out)
+	for _, i := range overdue {
+		task := app.tasks[i]
+		due, _ := storedDue(task.Due)
+		days := daysBetween(due, now)
+		unit := "days"
+		if days == 1 {
+			unit = "day"
+		}
+		fmt.Fprintf(app.out, "%d. %s %s (due %s, %d %s overdue)\n", app.number(i), statusMark(task), task.Description, app.formatDate(task.Due), days, unit)
+	}
+	fmt.Fprintln(app.out)
+}
+
+// listStale shows open tasks created more than days ago, oldest first.
+// Tasks saved before creation times were recorded are left out.
+func (app *TodoApp) listStale(arg string) error {
+	days := 7
+	if arg != "" {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 {
+			return usageError("Not a number of days", arg, "stale [days]")
+		}
+		days = n
+	}
+	now := today()
+	var stale []int
+	for i, task := range app.tasks {
+		if task.isOpen() && !task.CreatedAt.IsZero() && daysBetween(task.CreatedAt.Local(), now) > days {
+			stale = append(stale, i)
+		}
+	}
+	if len(stale) == 0 {
+		fmt.Fprintf(app.out, "No open tasks older than %d days.\n", days)
+		return nil
+	}
+	sort.SliceStable(stale, func(i, j int) bool {
+		return app.tasks[stale[i]].CreatedAt.Before(app.tasks[stale[j]].CreatedAt)
+	})
+	fmt.Fprintln(app.out)
+	for _, i := range stale {
+		task := app.tasks[i]
+		fmt.Fprintf(app.out, "%d. %s %s (open %d days)\n", app.number(i), statusMark(task), task.Description, daysBetween(task.CreatedAt.Local(), now))
+	}
+	fmt.Fprintln(app.out)
+	return nil
+}
+
+func (app *TodoApp) listDeferred() {
+	now := today()
+	found := false
+	for i, task := range app.tasks {
+		if task.isDeferred(now) {
+			if !found {
+				fmt.Fprintln(app.out)
+			}
+			found = true
+			fmt.Fprintf(app.out, "%d. %s %s (starts %s)\n", app.number(i), statusMark(task), task.Description, app.formatDate(task.StartDate))
+		}
+	}
+	if found {
+		fmt.Fprintln(app.out)
+	} else {
+		fmt.Fprintln(app.out, "No deferred tasks.")
+	}
+}
+
+func (app *TodoApp) showTask(index int, asJSON bool) error {
+	if index < 0 || index >= len(app.tasks) {
+		return app.invalidTaskNumber(index)
+	}
+	task := app.tasks[index]
+	if asJSON {
+		data, err := json.MarshalIndent(task, "", "  ")
+		if err != nil {
+			return fmt.Errorf("Error encoding JSON: %v", err)
+		}
+		fmt.Fprintln(app.out, string(data))
+		return nil
+	}
+
+	status := "open"
+	if task.IsCompleted {
+		status = "done"
+	} else if task.Status != "" {
+		status = task.Status
+	}
+	fmt.Fprintf(app.out, "Task %d\n", app.number(index))
+	fmt.Fprintf(app.out, "  %-12s %d\n", "ID:", task.ID)
+	fmt.Fprintf(app.out, "  %-12s %s\n", "Description:", task.Description)
+	fmt.Fprintf(app.out, "  %-12s %s\n", "Status:", status)
+	fmt.Fprintf(app.out, "  %-12s %s\n", "Priority:", priorityNames[task.Priority])
+	if task.Due != "" {
+		fmt.Fprintf(app.out, "  %-12s %s\n", "Due:", app.formatDate(task.Due))
+	}
+	if task.Recur != "" {
+		fmt.Fprintf(app.out, "  %-12s %s\n", "Repeats:", task.Recur)
+	}
+	if task.Estimate > 0 {
+		fmt.Fprintf(app.out, "  %-12s %s\n", "Estimate:", formatEstimate(task.Estimate))
+	}
+	if blockers := app.openBlockers(task); len(blockers) > 0 {
+		numbers := make([]string, len(blockers))
+		for i, blocker := range blockers {
+			numbers[i] = strconv.Itoa(app.number(blocker))
+		}
+		fmt.Fprintf(app.out, "  %-12s %s\n", "Blocked by:", strings.Join(numbers, ", "))
+	}
+	if task.StartDate != "" {
+		fmt.Fprintf(app.out, "  %-12s %s\n", "Starts:", app.formatDate(task.StartDate))
+	}
+	if task.PlanDate != "" {
+		fmt.Fprintf(app.out, "  %-12s %s\n", "Planned:", app.formatDate(task.PlanDate))
+	}
+	if !task.CreatedAt.IsZero() {
+		fmt.Fprintf(app.out, "  %-12s %s\n", "Created:", app.formatDate(task.CreatedAt.Local().Format(dateTimeLayout)))
+	}
+	if !task.CompletedAt.IsZero() {
+		fmt.Fprintf(app.out, "  %-12s %s\n", "Completed:", app.formatDate(task.CompletedAt.Local().Format(dateTimeLayout)))
+	}
+	if len(task.Tags) > 0 {
+		fmt.Fprintf(app.out, "  %-12s #%s\n", "Tags:", strings.Join(task.Tags, " #"))
+	}
+	if task.Source != "" {
+		fmt.Fprintf(app.out, "  %-12s %s\n", "Source:", task.Source)
+	}
+	if len(task.Notes) > 0 {
+		fmt.Fprintln(app.out, "  Notes:")
+		for _, note := range task.Notes {
+			fmt.Fprintln(app.out, "    "+note)
+		}
+	}
+	return nil
+}
+
+func (app *TodoApp) setPriority(index int, value string) error {
+	if index < 0 || index >= len(app.tasks) {
+		return app.invalidTaskNumber(index)
+	}
+	priority, ok := parsePriority(value)
+	if !ok {
+		return usageError("Unknown priority", value, "p <task number> <none|low|medium|high>")
+	}
+	app.tasks[index].Priority = priority
+	app.dirty = true
+	fmt.Fprintln(app.out, "Priority set to", priorityNames[priority])
+	app.relist()
+	return nil
+}
+
+// bumpPriority moves a task's priority one level by step, stopping at none
+// and high.
+func (app *TodoApp) bumpPriority(index, step int) error {
+	if index < 0 || index >= len(app.tasks) {
+		return app.invalidTaskNumber(index)
+	}
+	task := &app.tasks[index]
+	priority := task.Priority + step
+	if priority < 0 || priority >= len(priorityNames) {
+		return fmt.Errorf("Priority is already %s.", priorityNames[task.Priority])
+	}
+	task.Priority = priority
+	app.dirty = true
+	fmt.Fprintln(app.out, "Priority set to", priorityNames[priority])
+	app.relist()
+	return nil
+}
+
+// sortByPriority orders by priority (highest first), then due date
+// (soonest first, undated last), then creation order. The sort is stable.
+func (app *TodoApp) sortByPriority() {
+	sort.SliceStable(app.tasks, func(i, j int) bool {
+		a, b := app.tasks[i], app.tasks[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		aDue, aOK := storedDue(a.Due)
+		bDue, bOK := storedDue(b.Due)
+		if aOK != bOK {
+			return aOK
+		}
+		if aOK && !aDue.Equal(bDue) {
+			return aDue.Before(bDue)
+		}
+		return a.ID < b.ID
+	})
+	app.dirty = true
+}
+
+// sortDoneByCompletion reorders completed tasks by when they were finished,
+// earliest first, within the slots they already occupy. Open tasks don't
+// move and completions without a timestamp go last.
+func (app *TodoApp) sortDoneByCompletion() {
+	var slots []int
+	var done []Task
+	for i, task := range app.tasks {
+		if task.IsCompleted {
+			slots = append(slots, i)
+			done = append(done, task)
+		}
+	}
+	sort.SliceStable(done, func(i, j int) bool {
+		a, b := done[i].CompletedAt, done[j].CompletedAt
+		if a.IsZero() != b.IsZero() {
+			return !a.IsZero()
+		}
+		return a.Before(b)
+	})
+	for k, i := range slots {
+		app.tasks[i] = done[k]
+	}
+	app.dirty = true
+}
+
+func (app *TodoApp) sortTasks(key string) error {
+	switch key {
+	case "p":
+		app.sortByPriority()
+	case "done":
+		app.sortDoneByCompletion()
+	default:
+		return usageError("Unknown sort key", key, "sort <p|done>")
+	}
+	app.relist()
+	return nil
+}
+
+func (app *TodoApp) setDue(index int, value string) error {
+	if index < 0 || index >= len(app.tasks) {
+		return app.invalidTaskNumber(index)
+	}
+	if strings.ToLower(value) == "none" {
+		app.tasks[index].Due = ""
+		app.dirty = true
+		fmt.Fprintln(app.out, "Due date cleared.")
+		app.relist()
+		return nil
+	}
+	due, ok := parseDue(value)
+	if !ok {
+		return errInvalidDueDate
+	}
+	app.tasks[index].Due = due
+	app.dirty = true
+	fmt.Fprintln(app.out, "Due", app.formatDate(due))
+	app.relist()
+	return nil
+}
+
+// shiftDueDates moves the due date of every open task by days, keeping any
+// time of day.
+func (app *TodoApp) shiftDueDates(args string) error {
+	const usage = "shift <days> [-f]"
+	args, force := forceFlag(args)
+	if args == "" {
+		return usageError("", "", usage)
+	}
+	days, err := strconv.Atoi(args)
+	if err != nil || days == 0 {
+		return usageError("Not a number of days", args, usage)
+	}
+	var matches []int
+	for i, task := range app.tasks {
+		if _, ok := storedDue(task.Due); ok && task.isOpen() {
+			matches = append(matches, i)
+		}
+	}
+	if len(matches) == 0 {
+		return errors.New("No open tasks have a due date.")
+	}
+	if ok, err := app.confirmBulk("reschedule", matches, force); !ok {
+		return err
+	}
+	for _, i := range matches {
+		due, _ := storedDue(app.tasks[i].Due)
+		app.tasks[i].Due = formatDueLike(app.tasks[i].Due, due.AddDate(0, 0, days))
+	}
+	app.dirty = true
+	fmt.Fprintf(app.out, "Shifted %d due date(s) by %+d day(s).\n", len(matches), days)
+	app.relist()
+	return nil
+}
+
+func (app *TodoApp) setStartDate(index int, value string) error {
+	if index < 0 || index >= len(app.tasks) {
+		return app.invalidTaskNumber(index)
+	}
+	if strings.ToLower(value) == "none" {
+		app.tasks[index].StartDate = ""
+		app.dirty = true
+		fmt.Fprintln(app.out, "Start date cleared.")
+		app.relist()
+		return nil
+	}
+	date, ok := parseDate(value)
+	if !ok {
+		return errInvalidDate
+	}
+	app.tasks[index].StartDate = date.Format(dateLayout)
+	app.dirty = true
+	fmt.Fprintln(app.out, "Starts on", app.formatDate(app.tasks[index].StartDate))
+	app.relist()
+	return nil
+}
+
+func (app *TodoApp) setLink(index int, value string) error {
+	if index < 0 || index >= len(app.tasks) {
+		return app.invalidTaskNumber(index)
+	}
+	if strings.ToLower(value) == "none" {
+		app.tasks[index].Link = ""
+		app.dirty = true
+		fmt.Fprintln(app.out, "Link cleared.")
+		app.relist()
+		return nil
+	}
+	if u, err := url.Parse(value); err != nil || u.Scheme == "" || strings.ContainsAny(value, " \t") {
+		return usageError("Not a URL", value, "link <task number> <url|none>")
+	}
+	app.tasks[index].Link = value
+	app.dirty = true
+	fmt.Fprintln(app.out, "Link set to", value)
+	app.relist()
+	return nil
+}
+
+// assignTask sets who a task is assigned to; "none" clears it.
+func (app *TodoApp) assignTask(index int, name string) error {
+	if index < 0 || index >= len(app.tasks) {
+		return app.invalidTaskNumber(index)
+	}
+	if strings.ToLower(name) == "none" {
+		app.tasks[index].Assignee = ""
+		app.dirty = true
+		fmt.Fprintln(app.out, "Assignee cleared.")
+		app.relist()
+		return nil
+	}
+	name = strings.TrimPrefix(name, "@")
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return usageError("Expected a single name", name, "assign <task number> <name|none>")
+	}
+	app.tasks[index].Assignee = name
+	app.dirty = true
+	fmt.Fprintf(app.out, "Task %d assigned to @%s.\n", app.number(index), name)
+	app.relist()
+	return nil
+}
+
+// setSomeday moves a task into or out of the someday list, which the main
+// listing leaves out.
+func (app *TodoApp) setSomeday(index int, someday bool) error {
+	if index < 0 || index >= len(app.tasks) {
+		return app.invalidTaskNumber(index)
+	}
+	task := &app.tasks[index]
+	if task.Someday == someday {
+		if someday {
+			return fmt.Errorf("Task %d is already on the someday list.", app.number(index))
+		}
+		return fmt.Errorf("Task %d isn't on the someday list.", app.number(index))
+	}
+	task.Someday = someday
+	app.dirty = true
+	if someday {
+		fmt.Fprintf(app.out, "Moved \"%s\" to someday.\n", task.Description)
+	} else {
+		fmt.Fprintf(app.out, "Activated \"%s\".\n", task.Description)
+	}
+	app.relist()
+	return nil
 }
 
-func NewTodoApp() *TodoApp {
-	app := &TodoApp{
-		fileName: "tasks.json",
+func (app *TodoApp) listSomeday() {
+	found := false
+	for i, task := range app.tasks {
+		if task.Someday {
+			if !found {
+				fmt.Fprintln(app.out)
+			}
+			found = true
+			fmt.Fprintf(app.out, "%d. %s %s\n", app.number(i), statusMark(task), task.Description)
+		}
+	}
+	if found {
+		fmt.Fprintln(app.out)
+	} else {
+		fmt.Fprintln(app.out, "No someday tasks.")
 	}
-	app.loadTasks()
-	return app
 }
 
-func (app *TodoApp) loadTasks() {
-	data, err := ioutil.ReadFile(app.fileName)
-	if err != nil {
-		if !os.IsNotExist(err) {
-			fmt.Println("Error reading file:", err)
-		}
+// relist shows the tasks after a change unless quiet mode is on.
+func (app *TodoApp) relist() {
+	if !app.quiet {
+		app.listTasks()
+	}
+}
+
+func (app *TodoApp) invalidTaskNumber(index int) error {
+	if len(app.tasks) == 0 {
+		return fmt.Errorf("Invalid task number %d: there are no tasks.", index+1)
+	}
+	return fmt.Errorf("Invalid task number %d: choose 1-%d.", index+1, len(app.tasks))
+}
+
+func (app *TodoApp) setCompleted(index int, completed bool) {
+	task := &app.tasks[index]
+	if task.IsCompleted == completed {
 		return
 	}
-	err = json.Unmarshal(data, &app.tasks)
-	if err != nil {
-		fmt.Println("Error parsing JSON:", err)
+	task.Status = ""
+	app.dirty = true
+	if completed && task.Recur != "" {
+		occurrence := *task
+		if app.completeOccurrence(task) {
+			app.logEvent("complete", occurrence)
+			return
+		}
+	}
+	task.IsCompleted = completed
+	if completed {
+		task.CompletedAt = time.Now()
+		app.logEvent("complete", *task)
+		app.completeParent(*task)
+	} else {
+		task.CompletedAt = time.Time{}
 	}
 }
 
-func (app *TodoApp) saveTasks() {
-	data, err := json.Marshal(app.tasks)
-	if err != nil {
-		fmt.Println("Error encoding JSON:", err)
+// sortCompletedLast moves completed tasks below open ones when the
+// completedLast preference is on, keeping the order within each group.
+func (app *TodoApp) sortCompletedLast() {
+	if !app.config.CompletedLast {
 		return
 	}
-	err = ioutil.WriteFile(app.fileName, data, 0644)
-	if err != nil {
-		fmt.Println("Error writing file:", err)
+	sort.SliceStable(app.tasks, func(i, j int) bool {
+		return app.tasks[i].isOpen() && !app.tasks[j].isOpen()
+	})
+	app.dirty = true
+}
+
+// checkTaskNumbers rejects indices that are out of range or repeated.
+func (app *TodoApp) checkTaskNumbers(indices []int) error {
+	seen := make(map[int]bool)
+	for _, index := range indices {
+		if index < 0 || index >= len(app.tasks) {
+			return app.invalidTaskNumber(index)
+		}
+		if seen[index] {
+			return fmt.Errorf("Task %d is listed more than once.", app.number(index))
+		}
+		seen[index] = true
+	}
+	return nil
+}
+
+// confirmBulk shows the tasks a command is about to change and asks before
+// going ahead. A single task, or force, needs no confirmation.
+func (app *TodoApp) confirmBulk(action string, indices []int, force bool) (bool, error) {
+	if force || len(indices) < 2 {
+		return true, nil
+	}
+	if !app.interactive() {
+		return false, errNeedsForce
 	}
+	fmt.Fprintf(app.out, "This will %s %d tasks:\n", action, len(indices))
+	for _, index := range indices {
+		app.printTask(index, app.tasks[index])
+	}
+	return app.confirm("Continue?", false)
 }
 
-func (app *TodoApp) addTask(description string) {
-	app.tasks = append(app.tasks, Task{Description: description, IsCompleted: false})
-	app.saveTasks()
-	app.listTasks()
+func (app *TodoApp) toggleTaskCompletion(indices []int, force bool) error {
+	return app.toggleAndMove(indices, force, app.config.MoveDone)
 }
 
-func (app *TodoApp) listTasks() {
-	if len(app.tasks) == 0 {
-		fmt.Println("No tasks.")
-	} else {
-		fmt.Println()
-		for i, task := range app.tasks {
-			status := "[ ]"
-			if task.IsCompleted {
-				status = "[X]"
-			}
-			fmt.Printf("%d. %s %s\n", i+1, status, task.Description)
+// toggleAndMove toggles completion and, with moveDone, moves tasks that
+// became complete to the end of the list in the order given.
+func (app *TodoApp) toggleAndMove(indices []int, force, moveDone bool) error {
+	if err := app.checkTaskNumbers(indices); err != nil {
+		return err
+	}
+	if ok, err := app.confirmBulk("toggle", indices, force); !ok {
+		return err
+	}
+	var done []int
+	for _, index := range indices {
+		app.setCompleted(index, !app.tasks[index].IsCompleted)
+		if app.tasks[index].IsCompleted {
+			done = append(done, app.tasks[index].ID)
+		}
+	}
+	if moveDone {
+		for _, id := range done {
+			index := app.taskIndexByID(id)
+			task := app.tasks[index]
+			app.tasks = append(append(app.tasks[:index], app.tasks[index+1:]...), task)
 		}
-		fmt.Println()
 	}
+	app.sortCompletedLast()
+	app.relist()
+	return nil
 }
 
-func (app *TodoApp) toggleTaskCompletion(index int) {
-	if index >= 0 && index < len(app.tasks) {
-		app.tasks[index].IsCompleted = !app.tasks[index].IsCompleted
-		app.saveTasks()
-		app.listTasks()
+// cancelTask marks a task abandoned, or reopens it if it already was.
+func (app *TodoApp) cancelTask(index int) error {
+	if index < 0 || index >= len(app.tasks) {
+		return app.invalidTaskNumber(index)
+	}
+	task := &app.tasks[index]
+	if task.Status == statusCancelled {
+		task.Status = ""
+		fmt.Fprintln(app.out, "Task reopened.")
 	} else {
-		fmt.Println("Invalid task number.")
+		task.Status = statusCancelled
+		task.IsCompleted = false
+		task.CompletedAt = time.Time{}
+		app.logEvent("cancel", *task)
+		fmt.Fprintln(app.out, "Task cancelled.")
 	}
+	app.dirty = true
+	app.sortCompletedLast()
+	app.relist()
+	return nil
 }
 
-func (app *TodoApp) removeTask(index int) {
-	if index >= 0 && index < len(app.tasks) {
-		app.tasks = append(app.tasks[:index], app.tasks[index+1:]...)
-		app.saveTasks()
-		app.listTasks()
+// startTask marks an open task in progress, or back to todo if it already was.
+func (app *TodoApp) startTask(index int) error {
+	if index < 0 || index >= len(app.tasks) {
+		return app.invalidTaskNumber(index)
+	}
+	task := &app.tasks[index]
+	if !task.isOpen() {
+		return fmt.Errorf("Task %d isn't open.", app.number(index))
+	}
+	if task.Status == statusDoing {
+		task.Status = ""
+		fmt.Fprintln(app.out, "Task moved back to todo.")
 	} else {
-		fmt.Println("Invalid task number.")
+		task.Status = statusDoing
+		fmt.Fprintln(app.out, "Task in progress.")
+	}
+	app.dirty = true
+	app.relist()
+	return nil
+}
+
+// showBoard lists tasks under Todo, Doing and Done headings, keeping their
+// usual numbers. Cancelled tasks count as done.
+func (app *TodoApp) showBoard() {
+	var todo, doing, done []int
+	for i, task := range app.tasks {
+		switch {
+		case task.Someday:
+		case !task.isOpen():
+			done = append(done, i)
+		case task.Status == statusDoing:
+			doing = append(doing, i)
+		default:
+			todo = append(todo, i)
+		}
+	}
+	fmt.Fprintln(app.out)
+	for _, column := range []struct {
+		title   string
+		indices []int
+	}{{"Todo", todo}, {"Doing", doing}, {"Done", done}} {
+		fmt.Fprintf(app.out, "%s (%d)\n", column.title, len(column.indices))
+		for _, index := range column.indices {
+			fmt.Fprint(app.out, "  ")
+			app.printTask(index, app.tasks[index])
+		}
+		fmt.Fprintln(app.out)
+	}
+}
+
+func (app *TodoApp) completeWithNote(index int, note string) error {
+	if index < 0 || index >= len(app.tasks) {
+		return app.invalidTaskNumber(index)
+	}
+	app.setCompleted(index, true)
+	app.tasks[index].Notes = append(app.tasks[index].Notes, time.Now().Format("2006-01-02 15:04")+" "+note)
+	app.dirty = true
+	app.sortCompletedLast()
+	fmt.Fprintln(app.out, "Completed with note.")
+	app.relist()
+	return nil
+}
+
+func (app *TodoApp) removeTasks(indices []int, force bool) error {
+	if err := app.checkTaskNumbers(indices); err != nil {
+		return err
+	}
+	if ok, err := app.confirmBulk("delete", indices, force); !ok {
+		return err
 	}
+	sorted := append([]int(nil), indices...)
+	sort.Sort(sort.Reverse(sort.IntSlice(sorted)))
+	for _, index := range sorted {
+		app.tasks = append(app.tasks[:index], app.tasks[index+1:]...)
+	}
+	if len(sorted) > 1 {
+		fmt.Fprintf(app.out, "Deleted %d tasks.\n", len(sorted))
+	}
+	app.dirty = true
+	app.relist()
+	return nil
+}
+
+// compactTasks trims descriptions and drops tasks whose description
+// repeats an earlier one, and with dropDone also completed tasks. It shows
+// what will change and asks first unless force is set.
+func (app *TodoApp) compactTasks(args string) error {
+	const usage = "compact [-done] [-f]"
+	args, force := forceFlag(args)
+	dropDone := false
+	switch args {
+	case "":
+	case "-done":
+		dropDone = true
+	default:
+		return usageError("Unknown option", args, usage)
+	}
+
+	seen := make(map[string]bool)
+	var kept []Task
+	var changes []string
+	for i, task := range app.tasks {
+		description := strings.TrimSpace(task.Description)
+		key := foldText(description)
+		switch {
+		case dropDone && task.IsCompleted:
+			changes = append(changes, fmt.Sprintf("  remove completed: %d. %s", app.number(i), description))
+		case seen[key]:
+			changes = append(changes, fmt.Sprintf("  remove duplicate: %d. %s", app.number(i), description))
+		default:
+			if description != task.Description {
+				changes = append(changes, fmt.Sprintf("  trim spaces:      %d. %s", app.number(i), description))
+				task.Description = description
+			}
+			seen[key] = true
+			kept = append(kept, task)
+		}
+	}
+	if len(changes) == 0 {
+		if superseded := app.supersededLines(); superseded > 0 {
+			app.saved = nil
+			app.dirty = true
+			fmt.Fprintf(app.out, "Rewrote the tasks file without %d superseded line(s).\n", superseded)
+			return nil
+		}
+		fmt.Fprintln(app.out, "Nothing to compact.")
+		return nil
+	}
+	fmt.Fprintln(app.out, "Compacting will:")
+	for _, change := range changes {
+		fmt.Fprintln(app.out, change)
+	}
+	if ok, err := app.confirm("Continue?", force); !ok {
+		return err
+	}
+	removed := len(app.tasks) - len(kept)
+	app.tasks = kept
+	app.saved = nil
+	app.dirty = true
+	fmt.Fprintf(app.out, "Removed %d task(s), trimmed %d.\n", removed, len(changes)-removed)
+	app.relist()
+	return nil
+}
+
+// reorderTasks puts the tasks in the given order, which must name every
+// task exactly once.
+func (app *TodoApp) reorderTasks(indices []int) error {
+	if err := app.checkTaskNumbers(indices); err != nil {
+		return err
+	}
+	if len(indices) != len(app.tasks) {
+		return fmt.Errorf("List all %d tasks to reorder them; got %d.", len(app.tasks), len(indices))
+	}
+	reordered := make([]Task, 0, len(indices))
+	for _, index := range indices {
+		reordered = append(reordered, app.tasks[index])
+	}
+	app.tasks = reordered
+	app.dirty = true
+	app.relist()
+	return nil
 }
 
-func (app *TodoApp) moveTaskUp(index int) {
+func (app *TodoApp) moveTaskUp(index int) error {
 	if index > 0 && index < len(app.tasks) {
 		app.tasks[index], app.tasks[index-1] = app.tasks[index-1], app.tasks[index]
-		app.saveTasks()
-		app.listTasks()
-	} else {
-		fmt.Println("Cannot move task up.")
+		app.dirty = true
+		app.relist()
+		return nil
 	}
+	return errors.New("Cannot move task up.")
 }
 
-func (app *TodoApp) moveTaskDown(index int) {
+func (app *TodoApp) moveTaskDown(index int) error {
 	if index >= 0 && index < len(app.tasks)-1 {
 		app.tasks[index], app.tasks[index+1] = app.tasks[index+1], app.tasks[index]
-		app.saveTasks()
-		app.listTasks()
-	} else {
-		fmt.Println("Cannot move task down.")
+		app.dirty = true
+		app.relist()
+		return nil
 	}
+	return errors.New("Cannot move task down.")
 }
 
-func (app *TodoApp) renameTask(index int, newDescription string) {
-	if index >= 0 && index < len(app.tasks) {
-		oldDescription := app.tasks[index].Description
-		app.tasks[index].Description = newDescription
-		app.saveTasks()
-		fmt.Println("  From:", oldDescription)
-		fmt.Println("  To:  ", newDescription)
-		app.listTasks()
-	} else {
-		fmt.Println("Invalid task number.")
+func (app *TodoApp) renameTask(index int, newDescription string) error {
+	if index < 0 || index >= len(app.tasks) {
+		return app.invalidTaskNumber(index)
+	}
+	oldDescription := app.tasks[index].Description
+	app.tasks[index].Description = newDescription
+	app.dirty = true
+	fmt.Fprintln(app.out, "  From:", oldDescription)
+	fmt.Fprintln(app.out, "  To:  ", newDescription)
+	app.relist()
+	return nil
+}
+
+func (app *TodoApp) appendToTask(index int, text string) error {
+	if index < 0 || index >= len(app.tasks) {
+		return app.invalidTaskNumber(index)
 	}
+	app.tasks[index].Description = strings.TrimSpace(app.tasks[index].Description) + " " + text
+	app.dirty = true
+	fmt.Fprintln(app.out, "  Now:", app.tasks[index].Description)
+	app.relist()
+	return nil
 }
 
-func (app *TodoApp) processCommand(command string) {
-	parts := strings.SplitN(command, " ", 2)
-	action := strings.ToLower(parts[0])
+// input returns the scanner over app.in, creating it on first use so that
+// in can be replaced after NewTodoApp.
+func (app *TodoApp) input() *bufio.Scanner {
+	if app.scanner == nil {
+		app.scanner = bufio.NewScanner(app.in)
+	}
+	return app.scanner
+}
 
-	switch action {
-	case "a":
-		if len(parts) > 1 {
-			app.addTask(parts[1])
-		} else {
-			fmt.Println("Usage: a <task description>")
+// interactive reports whether input comes from a person at a terminal.
+func (app *TodoApp) interactive() bool {
+	file, ok := app.in.(*os.File)
+	return ok && isTerminal(file)
+}
+
+var errNeedsForce = errors.New("Not confirmed: input isn't a terminal. Use -f to confirm in scripts.")
+
+// confirm asks a yes/no question on the input stream, unless force is set.
+// Anything but "y" or "yes", including end of input, counts as no and
+// prints "Cancelled.". When input isn't a terminal it doesn't ask, so that
+// piped commands aren't read as answers, and fails with errNeedsForce.
+func (app *TodoApp) confirm(question string, force bool) (bool, error) {
+	if force {
+		return true, nil
+	}
+	if !app.interactive() {
+		return false, errNeedsForce
+	}
+	fmt.Fprint(app.out, question+" [y/N] ")
+	scanner := app.input()
+	if !scanner.Scan() {
+		fmt.Fprintln(app.out)
+		fmt.Fprintln(app.out, "Cancelled.")
+		return false, nil
+	}
+	answer := strings.ToLower(strings.TrimSpace(scanner.Text()))
+	if answer != "y" && answer != "yes" {
+		fmt.Fprintln(app.out, "Cancelled.")
+		return false, nil
+	}
+	return true, nil
+}
+
+// clearMetadata strips a task back to its description and completion,
+// keeping only its ID and creation time besides.
+func (app *TodoApp) clearMetadata(index int, force bool) error {
+	if index < 0 || index >= len(app.tasks) {
+		return app.invalidTaskNumber(index)
+	}
+	task := &app.tasks[index]
+	if ok, err := app.confirm(fmt.Sprintf("Clear all metadata (tags, priority, dates, notes, recurrence, estimate, assignee, link, parent and dependencies) from \"%s\"?", task.Description), force); !ok {
+		return err
+	}
+	cleared := Task{ID: task.ID, Description: task.Description, IsCompleted: task.IsCompleted, CompletedAt: task.CompletedAt, CreatedAt: task.CreatedAt}
+	if task.Status == statusCancelled {
+		cleared.Status = statusCancelled
+	}
+	*task = cleared
+	app.dirty = true
+	fmt.Fprintln(app.out, "Metadata cleared.")
+	app.relist()
+	return nil
+}
+
+func (app *TodoApp) tagTask(index int, tags []string) error {
+	if index < 0 || index >= len(app.tasks) {
+		return app.invalidTaskNumber(index)
+	}
+	task := &app.tasks[index]
+	for _, tag := range tags {
+		tag = normalizeTag(tag)
+		if tag != "" && !task.hasTag(tag) {
+			task.Tags = append(task.Tags, tag)
 		}
-	case "t":
-		app.listTasks()
-	case "x":
-		if len(parts) > 1 {
-			taskNumber, err := strconv.Atoi(parts[1])
-			if err == nil {
-				app.toggleTaskCompletion(taskNumber - 1)
-			} else {
-				fmt.Println("Invalid task number.")
+	}
+	app.dirty = true
+	app.relist()
+	return nil
+}
+
+func (app *TodoApp) untagTask(index int, tags []string) error {
+	if index < 0 || index >= len(app.tasks) {
+		return app.invalidTaskNumber(index)
+	}
+	task := &app.tasks[index]
+	for _, tag := range tags {
+		tag = foldText(normalizeTag(tag))
+		for i, t := range task.Tags {
+			if foldText(t) == tag {
+				task.Tags = append(task.Tags[:i], task.Tags[i+1:]...)
+				break
 			}
-		} else {
-			fmt.Println("Usage: x <task number>")
 		}
-	case "d":
-		if len(parts) > 1 {
-			taskNumber, err := strconv.Atoi(parts[1])
-			if err == nil {
-				app.removeTask(taskNumber - 1)
-			} else {
-				fmt.Println("Invalid task number.")
-			}
-		} else {
-			fmt.Println("Usage: d <task number>")
+	}
+	if len(task.Tags) == 0 {
+		task.Tags = nil
+	}
+	app.dirty = true
+	app.relist()
+	return nil
+}
+
+// completeTagged marks every open task carrying tag complete, asking first
+// when that would close more than one.
+func (app *TodoApp) completeTagged(tag string, force bool) error {
+	tag = normalizeTag(tag)
+	var matches []int
+	for i, task := range app.tasks {
+		if task.isOpen() && task.hasTag(tag) {
+			matches = append(matches, i)
 		}
-	case "h":
-		if len(parts) > 1 {
-			taskNumber, err := strconv.Atoi(parts[1])
-			if err == nil {
-				app.moveTaskUp(taskNumber - 1)
-			} else {
-				fmt.Println("Invalid task number.")
-			}
-		} else {
-			fmt.Println("Usage: h <task number>")
+	}
+	if len(matches) == 0 {
+		return fmt.Errorf("No open tasks tagged #%s.", tag)
+	}
+	if ok, err := app.confirmBulk("complete", matches, force); !ok {
+		return err
+	}
+	for _, i := range matches {
+		app.setCompleted(i, true)
+	}
+	app.sortCompletedLast()
+	fmt.Fprintf(app.out, "Completed %d task(s) tagged #%s.\n", len(matches), tag)
+	app.relist()
+	return nil
+}
+
+// readLines prompts for lines until a blank line or end of input.
+func (app *TodoApp) readLines(prompt string) []string {
+	var lines []string
+	scanner := app.input()
+	for {
+		fmt.Fprint(app.out, prompt)
+		if !scanner.Scan() {
+			fmt.Fprintln(app.out)
+			break
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			break
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func (app *TodoApp) splitTask(index int) error {
+	if index < 0 || index >= len(app.tasks) {
+		return app.invalidTaskNumber(index)
+	}
+	fmt.Fprintln(app.out, "Splitting:", app.tasks[index].Description)
+	fmt.Fprintln(app.out, "Enter the new tasks, one per line. Finish with a blank line.")
+	descriptions := app.readLines("  + ")
+	if len(descriptions) == 0 {
+		fmt.Fprintln(app.out, "Split cancelled.")
+		return nil
+	}
+
+	replacements := make([]Task, 0, len(descriptions))
+	for _, description := range descriptions {
+		replacements = append(replacements, app.newTask(description))
+	}
+	rest := append(replacements, app.tasks[index+1:]...)
+	app.tasks = append(app.tasks[:index], rest...)
+	app.dirty = true
+	fmt.Fprintf(app.out, "Split into %d tasks.\n", len(replacements))
+	app.relist()
+	return nil
+}
+
+// combineTasks merges the tasks into the one listed first, keeping its
+// position and metadata. The result is complete only if all parts were.
+func (app *TodoApp) combineTasks(indices []int) error {
+	if err := app.checkTaskNumbers(indices); err != nil {
+		return err
+	}
+	if len(indices) < 2 {
+		return errors.New("Choose at least two tasks to combine.")
+	}
+	sorted := append([]int(nil), indices...)
+	sort.Ints(sorted)
+
+	merged := app.tasks[sorted[0]]
+	merged.Notes = append([]string(nil), merged.Notes...)
+	for _, index := range sorted[1:] {
+		task := app.tasks[index]
+		merged.Description += "; " + task.Description
+		merged.Notes = append(merged.Notes, task.Notes...)
+		merged.IsCompleted = merged.IsCompleted && task.IsCompleted
+	}
+	app.tasks[sorted[0]] = merged
+	for i := len(sorted) - 1; i > 0; i-- {
+		app.tasks = append(app.tasks[:sorted[i]], app.tasks[sorted[i]+1:]...)
+	}
+	app.dirty = true
+	fmt.Fprintf(app.out, "Combined %d tasks into: %s\n", len(sorted), merged.Description)
+	app.relist()
+	return nil
+}
+
+// completeFuzzy completes the open task that best matches query after
+// asking. When the best match is only approximate or shared by several
+// tasks, the candidates are listed and the user picks one by number, or,
+// when input isn't a terminal, is asked for a more specific query.
+func (app *TodoApp) completeFuzzy(query string, force bool) error {
+	type candidate struct{ index, score int }
+	var candidates []candidate
+	for i, task := range app.tasks {
+		if score, ok := fuzzyScore(query, task.Description); ok && task.isOpen() {
+			candidates = append(candidates, candidate{i, score})
+		}
+	}
+	if len(candidates) == 0 {
+		return fmt.Errorf("No open task matches %q.", query)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score < candidates[j].score })
+
+	if candidates[0].score == 0 && (len(candidates) == 1 || candidates[1].score > 0) {
+		index := candidates[0].index
+		if ok, err := app.confirm(fmt.Sprintf("Complete \"%s\"?", app.tasks[index].Description), force); !ok {
+			return err
+		}
+		return app.toggleTaskCompletion([]int{index}, true)
+	}
+
+	if len(candidates) > 5 {
+		candidates = candidates[:5]
+	}
+	fmt.Fprintln(app.out, "Closest matches:")
+	for _, c := range candidates {
+		app.printTask(c.index, app.tasks[c.index])
+	}
+	if !app.interactive() {
+		return fmt.Errorf("More than one task could match %q; use a more specific query or x <task number>.", query)
+	}
+	fmt.Fprint(app.out, "Task number to complete (blank to cancel): ")
+	scanner := app.input()
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) == "" {
+		fmt.Fprintln(app.out, "Cancelled.")
+		return nil
+	}
+	index, err := app.taskNumberArg(strings.TrimSpace(scanner.Text()), "task number")
+	if err != nil {
+		return err
+	}
+	for _, c := range candidates {
+		if c.index == index {
+			return app.toggleTaskCompletion([]int{index}, true)
 		}
-	case "l":
-		if len(parts) > 1 {
-			taskNumber, err := strconv.Atoi(parts[1])
-			if err == nil {
-				app.moveTaskDown(taskNumber - 1)
-			} else {
-				fmt.Println("Invalid task number.")
+	}
+	return fmt.Errorf("Task %d isn't one of the matches.", app.number(index))
+}
+
+// findByDescription returns the only task whose trimmed description is
+// exactly description.
+func (app *TodoApp) findByDescription(description string) (int, error) {
+	match := -1
+	for i, task := range app.tasks {
+		if strings.TrimSpace(task.Description) == description {
+			if match >= 0 {
+				return 0, errors.New("More than one task matches that description.")
 			}
-		} else {
-			fmt.Println("Usage: l <task number>")
-		}
-	case "r":
-		if len(parts) > 1 {
-			subParts := strings.SplitN(parts[1], " ", 2)
-			if len(subParts) == 2 {
-				taskNumber, err := strconv.Atoi(subParts[0])
-				if err == nil {
-					app.renameTask(taskNumber-1, subParts[1])
-				} else {
-					fmt.Println("Invalid task number.")
-				}
-			} else {
-				fmt.Println("Usage: r <task number> <new task description>")
+			match = i
+		}
+	}
+	if match < 0 {
+		return 0, errors.New("No task matches that description.")
+	}
+	return match, nil
+}
+
+// renameByDescription renames the task whose description exactly matches
+// the leading words of args. With two quoted arguments the split is explicit;
+// otherwise every split point is tried, and a split that matches more than
+// one way is refused.
+func (app *TodoApp) renameByDescription(args string) error {
+	if strings.Contains(args, `"`) {
+		quoted, err := splitArgs(args)
+		if err != nil {
+			return err
+		}
+		if len(quoted) != 2 {
+			return usageError("Expected two quoted descriptions", args, `rn "<old description>" "<new description>"`)
+		}
+		index, err := app.findByDescription(strings.TrimSpace(quoted[0]))
+		if err != nil {
+			return err
+		}
+		return app.renameTask(index, quoted[1])
+	}
+
+	words := strings.Fields(args)
+	matchIndex := -1
+	matchCount := 0
+	newDescription := ""
+	for k := 1; k < len(words); k++ {
+		old := strings.Join(words[:k], " ")
+		for i, task := range app.tasks {
+			if strings.TrimSpace(task.Description) == old {
+				matchIndex = i
+				matchCount++
+				newDescription = strings.Join(words[k:], " ")
 			}
-		} else {
-			fmt.Println("Usage: r <task number> <new task description>")
 		}
-	case "q":
-		os.Exit(0)
-	case "?":
-		app.printHelp()
-	default:
-		fmt.Println("Unknown command. Type \"?\" for help.")
 	}
+	switch {
+	case matchCount == 0:
+		return errors.New("No task matches that description.")
+	case matchCount > 1:
+		return errors.New("More than one task matches that description.")
+	}
+	return app.renameTask(matchIndex, newDescription)
+}
+
+// showRaw prints the tasks file as it is on disk, which may lag behind
+// the list in memory until the next save.
+func (app *TodoApp) showRaw() error {
+	data, err := ioutil.ReadFile(app.fileName)
+	if os.IsNotExist(err) {
+		fmt.Fprintf(app.out, "%s doesn't exist yet.\n", app.fileName)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Error reading file: %v", err)
+	}
+	if isEncrypted(data) {
+		if data, err = app.openData(app.fileName, data); err != nil {
+			return fmt.Errorf("Error reading file: %v", err)
+		}
+		fmt.Fprintln(app.out, "(encrypted on disk; decrypted contents follow)")
+	}
+	app.out.Write(data)
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		fmt.Fprintln(app.out)
+	}
+	return nil
 }
 
-func (app *TodoApp) printHelp() {
-	fmt.Println("Available commands:")
-	fmt.Println("  a <task description> - Add a new task")
-	fmt.Println("  t - List all tasks")
-	fmt.Println("  x <task number> - Mark task as complete/incomplete")
-	fmt.Println("  d <task number> - Remove task")
-	fmt.Println("  h <task number> - Move task higher")
-	fmt.Println("  l <task number> - Move task lower")
-	fmt.Println("  r <task number> <new description> - Rename task")
-	fmt.Println("  ? - Show this help message")
-	fmt.Println("  q - Quit the application")
+func (app *TodoApp) showDiff() error {
+	var saved []Task
+	data, err := ioutil.ReadFile(app.fileName)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Error reading file: %v", err)
+	}
+	if err == nil {
+		if data, err = app.openData(app.fileName, data); err != nil {
+			return fmt.Errorf("Error reading file: %v", err)
+		}
+		if saved, _, _, err = decodeTasks(data); err != nil {
+			return fmt.Errorf("Error parsing JSON: %v", err)
+		}
+	}
+	assignIDs(saved)
+
+	savedByID := make(map[int]Task)
+	for _, task := range saved {
+		savedByID[task.ID] = task
+	}
+	changes := 0
+	for i, task := range app.tasks {
+		old, ok := savedByID[task.ID]
+		if !ok {
+			fmt.Fprintf(app.out, "  + %d. %s %s\n", app.number(i), statusMark(task), task.Description)
+			changes++
+		} else if !reflect.DeepEqual(old, task) {
+			fmt.Fprintf(app.out, "  ~ %d. %s %s (was: %s %s)\n", app.number(i), statusMark(task), task.Description, statusMark(old), old.Description)
+			changes++
+		}
+		delete(savedByID, task.ID)
+	}
+	for _, task := range saved {
+		if _, ok := savedByID[task.ID]; ok {
+			fmt.Fprintf(app.out, "  - %s %s\n", statusMark(task), task.Description)
+			changes++
+		}
+	}
+	if changes == 0 {
+		fmt.Fprintln(app.out, "No unsaved changes.")
+	}
+	return nil
+}
+
+func statusMark(task Task) string {
+	if task.IsCompleted {
+		return "[X]"
+	}
+	if task.Status == statusCancelled {
+		return "[-]"
+	}
+	if task.Status == statusDoing {
+		return "[~]"
+	}
+	return "[ ]"
+}
+
+func (app *TodoApp) openCount() int {
+	count := 0
+	for _, task := range app.tasks {
+		if task.isOpen() {
+			count++
+		}
+	}
+	return count
+}
+
+func (app *TodoApp) prompt(interactive bool) string {
+	if !interactive || !app.config.PromptCount {
+		return "> "
+	}
+	open := app.openCount()
+	color := colorYellow
+	if open == 0 {
+		color = colorGreen
+	}
+	return app.colorize(color, fmt.Sprintf("(%d)", open)) + " > "
+}
+
+// runStartupFile executes the commands in ~/.todorc, if it exists. Blank
+// lines and lines starting with "#" are skipped.
+func (app *TodoApp) runStartupFile() {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return
+	}
+	file, err := os.Open(filepath.Join(home, ".todorc"))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Fprintln(app.out, "Error reading .todorc:", err)
+		}
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		app.execute(line)
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(app.out, "Error reading .todorc:", err)
+	}
 }
 
 func (app *TodoApp) run() {
-	app.listTasks() // Display tasks at the start
+	interactive := app.interactive()
+	if interactive && app.firstRun() {
+		app.runSetup()
+	}
+	app.runStartupFile()
+	app.showStartView()
 
-	scanner := bufio.NewScanner(os.Stdin)
+	if interactive && app.config.Notify {
+		go app.watchDue(time.Now())
+	}
+	scanner := app.input()
 	for {
-		fmt.Print("> ")
+		fmt.Fprint(app.out, app.prompt(interactive))
 		if scanner.Scan() {
 			input := scanner.Text()
 			if input != "" {
-				app.processCommand(input)
+				app.execute(input)
 			}
 		} else {
 			break
 		}
 	}
+	app.shutdown(interactive)
+}
+
+// shutdown runs when input ends: it saves anything pending so piped
+// scripts don't lose their last changes.
+func (app *TodoApp) shutdown(interactive bool) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	if err := app.input().Err(); err != nil {
+		fmt.Fprintln(app.out, "Error reading input:", err)
+	}
+	if err := app.flush(); err != nil {
+		fmt.Fprintln(app.out, err)
+	}
+	if interactive {
+		fmt.Fprintln(app.out)
+		fmt.Fprintln(app.out, "Goodbye.")
+	}
+}
+
+func programName() string {
+	return filepath.Base(os.Args[0])
+}
+
+// printUsage describes command-line use. It doesn't touch the data file.
+func printUsage() {
+	fmt.Printf("Usage: %s [flags] [command]\n", programName())
+	fmt.Println()
+	fmt.Println("With a command, run it once and exit. Without one, start the interactive prompt.")
+	fmt.Println()
+	fmt.Println("Flags:")
+	flag.CommandLine.SetOutput(os.Stdout)
+	flag.PrintDefaults()
+	fmt.Println()
+	printHelp(os.Stdout)
 }
 
 func main() {
-	app := NewTodoApp()
+	fileName := flag.String("file", "", "tasks file to use (default tasks.json, or the one chosen at setup)")
+	quiet := flag.Bool("quiet", false, "don't list tasks after each change")
+	openOnly := flag.Bool("open", false, "list open tasks and exit")
+	asJSON := flag.Bool("json", false, "with -open, print the tasks as JSON")
+	output := flag.String("output", "", "with a command, write its output to this file instead of stdout")
+	appendOutput := flag.Bool("append", false, "with -output, append to the file instead of overwriting it")
+	help := flag.Bool("help", false, "print usage and exit")
+	flag.BoolVar(help, "h", false, "print usage and exit")
+	flag.Usage = printUsage
+	flag.Parse()
+
+	if *help {
+		printUsage()
+		return
+	}
+
+	app := NewTodoApp(*fileName)
+	app.quiet = *quiet
+	command := strings.Join(flag.Args(), " ")
+	if *openOnly {
+		command = "open"
+		if *asJSON {
+			command += " -json"
+		}
+	}
+	if *output != "" {
+		if command == "" {
+			fmt.Fprintln(os.Stderr, "-output needs a command to run, e.g. -output tasks.txt plain")
+			os.Exit(2)
+		}
+		mode := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if *appendOutput {
+			mode = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+		file, err := os.OpenFile(*output, mode, 0644)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error opening output file:", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		app.out = file
+		app.errOut = os.Stderr
+	}
+	if command != "" {
+		if err := app.execute(command); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
 	app.run()
 }
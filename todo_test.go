@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestApp returns an app holding one open task per description, whose
+// output goes to the returned buffer and which never touches the disk.
+func newTestApp(t testing.TB, descriptions ...string) (*TodoApp, *bytes.Buffer) {
+	t.Helper()
+	var out bytes.Buffer
+	app := &TodoApp{in: strings.NewReader(""), out: &out, errOut: &out, quiet: true}
+	for _, description := range descriptions {
+		app.tasks = append(app.tasks, app.newTask(description))
+	}
+	return app, &out
+}
+
+// largeTestApp returns an app with n tasks using most of what a listing
+// shows: subtasks, dependencies, priorities, dates, tags, links and hidden
+// tasks.
+func largeTestApp(t testing.TB, n int) *TodoApp {
+	app, _ := newTestApp(t)
+	tomorrow := today().AddDate(0, 0, 1).Format(dateLayout)
+	for i := 0; i < n; i++ {
+		task := app.newTask(fmt.Sprintf("Task %d see https://example.com/%d", i, i))
+		task.Priority = i % 4
+		switch i % 7 {
+		case 0:
+			task.Due = tomorrow
+			task.Recur = "weekly"
+		case 1:
+			task.IsCompleted = true
+			task.CompletedAt = time.Now().Add(-48 * time.Hour)
+		case 2:
+			task.Tags = []string{"work", fmt.Sprintf("t%d", i%5)}
+			task.Assignee = "sam"
+		case 3:
+			task.Someday = true
+		case 4:
+			task.StartDate = tomorrow
+		case 5:
+			task.Estimate = 90
+			task.Link = "https://example.com/issue"
+		}
+		if i%10 == 9 {
+			task.Parent = app.tasks[i-3].ID
+		}
+		if i%13 == 12 {
+			task.BlockedBy = []int{app.tasks[i-5].ID}
+		}
+		app.tasks = append(app.tasks, task)
+	}
+	app.quiet = false
+	return app
+}
+
+// TestListTasksMatchesUncached checks that the buffered, cached listing
+// prints exactly what computing every lookup per line does.
+func TestListTasksMatchesUncached(t *testing.T) {
+	for _, relative := range []bool{false, true} {
+		app := largeTestApp(t, 500)
+		app.config.RelativeNumbers = relative
+
+		var want, got bytes.Buffer
+		app.out = &want
+		app.writeListing()
+		app.out = &got
+		app.listTasks()
+
+		if !bytes.Equal(got.Bytes(), want.Bytes()) {
+			t.Errorf("relative=%v: listing differs from uncached output\ngot:\n%s\nwant:\n%s", relative, got.String(), want.String())
+		}
+	}
+}
+
+func BenchmarkListTasks(b *testing.B) {
+	app := largeTestApp(b, 5000)
+	app.out = io.Discard
+	for b.Loop() {
+		app.listTasks()
+	}
+}
+
+// BenchmarkListTasksUncached is the listing as it was before lookups were
+// cached, for comparison.
+func BenchmarkListTasksUncached(b *testing.B) {
+	app := largeTestApp(b, 5000)
+	app.out = io.Discard
+	for b.Loop() {
+		app.writeListing()
+	}
+}
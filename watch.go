@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const watchInterval = time.Second
+
+func modTime(fileName string) time.Time {
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// watchFile reloads and relists whenever the tasks file changes on disk,
+// until a line (usually just Enter) is read from the input. Nothing is
+// saved while watching, so the app's own writes can't trigger a reload;
+// anything loading changes is saved once watching stops.
+func (app *TodoApp) watchFile() error {
+	if err := app.flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(app.out, "Watching %s for changes. Press Enter to stop.\n", app.fileName)
+	stop := make(chan struct{})
+	go func() {
+		app.input().Scan()
+		close(stop)
+	}()
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	last := modTime(app.fileName)
+	for {
+		select {
+		case <-stop:
+			// Reloads mirror someone else's edits, so there's nothing to undo.
+			app.skipJournal = true
+			fmt.Fprintln(app.out, "Stopped watching.")
+			return nil
+		case <-ticker.C:
+			if current := modTime(app.fileName); !current.Equal(last) {
+				last = current
+				app.tasks = nil
+				app.loadTasks()
+				fmt.Fprintf(app.out, "%s changed on disk.\n", app.fileName)
+				app.listTasks()
+			}
+		}
+	}
+}